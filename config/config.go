@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
@@ -18,16 +19,30 @@ type Config struct {
 }
 
 type DatabaseConfig struct {
-	Type        string `mapstructure:"type"`
-	Host        string `mapstructure:"host"`
-	Port        int    `mapstructure:"port"`
-	User        string `mapstructure:"user"`
-	Password    string `mapstructure:"password"`
-	Database    string `mapstructure:"database"`
-	SSLMode     string `mapstructure:"sslmode"`
-	MaxRetries  int    `mapstructure:"max_retries"`
-	MaxPoolSize int    `mapstructure:"max_pool_size"`
-	URL         string `mapstructure:"url"`
+	Type            string        `mapstructure:"type"`
+	Host            string        `mapstructure:"host"`
+	Port            int           `mapstructure:"port"`
+	User            string        `mapstructure:"user"`
+	Password        string        `mapstructure:"password"`
+	Database        string        `mapstructure:"database"`
+	SSLMode         string        `mapstructure:"sslmode"`
+	MaxRetries      int           `mapstructure:"max_retries"`
+	MaxPoolSize     int           `mapstructure:"max_pool_size"`
+	MaxIdle         int           `mapstructure:"max_idle"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+	ConnectTimeout  time.Duration `mapstructure:"connect_timeout"`
+	URL             string        `mapstructure:"url"`
+	// QueryTimeout applies to statements whose context has no deadline,
+	// including those in transactions; a query releases it when its rows close.
+	QueryTimeout       time.Duration `mapstructure:"query_timeout"`
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"`
+	// StmtCacheSize enables an LRU prepared statement cache of this size.
+	StmtCacheSize int `mapstructure:"stmt_cache_size"`
+	// Replicas lists read replica URLs; reads are routed to them when set.
+	Replicas              []string      `mapstructure:"replicas"`
+	ReplicaStrategy       string        `mapstructure:"replica_strategy"`
+	ReplicaHealthInterval time.Duration `mapstructure:"replica_health_interval"`
 }
 
 type MongoConfig struct {
@@ -119,8 +134,18 @@ func LoadFromEnv() (*Config, error) {
 				viper.GetString("MONGO_URI"),
 				viper.GetString("MONGODB_URI"),
 			),
-			MaxRetries:  viper.GetInt("DATABASE_MAX_RETRIES"),
-			MaxPoolSize: viper.GetInt("DATABASE_MAX_POOL_SIZE"),
+			MaxRetries:            viper.GetInt("DATABASE_MAX_RETRIES"),
+			MaxPoolSize:           viper.GetInt("DATABASE_MAX_POOL_SIZE"),
+			MaxIdle:               viper.GetInt("DATABASE_MAX_IDLE"),
+			ConnMaxLifetime:       viper.GetDuration("DATABASE_CONN_MAX_LIFETIME"),
+			ConnMaxIdleTime:       viper.GetDuration("DATABASE_CONN_MAX_IDLE_TIME"),
+			ConnectTimeout:        viper.GetDuration("DATABASE_CONNECT_TIMEOUT"),
+			QueryTimeout:          viper.GetDuration("DATABASE_QUERY_TIMEOUT"),
+			SlowQueryThreshold:    viper.GetDuration("DATABASE_SLOW_QUERY_THRESHOLD"),
+			StmtCacheSize:         viper.GetInt("DATABASE_STMT_CACHE_SIZE"),
+			Replicas:              splitList(viper.GetString("DATABASE_REPLICAS")),
+			ReplicaStrategy:       viper.GetString("DATABASE_REPLICA_STRATEGY"),
+			ReplicaHealthInterval: viper.GetDuration("DATABASE_REPLICA_HEALTH_INTERVAL"),
 		},
 		MongoDB: MongoConfig{
 			URI:      firstNonEmpty(viper.GetString("MONGODB_URI"), viper.GetString("MONGO_URI")),
@@ -174,6 +199,15 @@ func (c *Config) UsingMongoOnly() bool {
 		c.Database.Port == 0
 }
 
+// UsingSQLite reports whether database.type selects the SQLite backend
+func (c *Config) UsingSQLite() bool {
+	switch strings.ToLower(c.Database.Type) {
+	case "sqlite", "sqlite3":
+		return true
+	}
+	return false
+}
+
 func (c *Config) Validate() error {
 	// If pure Mongo usage, minimal checks
 	if c.UsingMongoOnly() {
@@ -188,7 +222,8 @@ func (c *Config) Validate() error {
 	if c.Database.Type == "" {
 		return fmt.Errorf("database.type is required")
 	}
-	if c.Database.URL == "" {
+	// SQLite only needs a file path (url or database); empty means in-memory
+	if c.Database.URL == "" && !c.UsingSQLite() {
 		if c.Database.Host == "" {
 			return fmt.Errorf("database.host is required when database.url is not provided")
 		}
@@ -222,6 +257,17 @@ func firstNonEmpty(vals ...string) string {
 	return ""
 }
 
+// splitList splits a comma separated env value, dropping empty entries
+func splitList(val string) []string {
+	var out []string
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 // Simple helper to show final resolved configuration (debug)
 func (c *Config) DebugPrint() {
 	log.Printf("CONFIG: db.type=%s db.url=%s mongo.uri=%s server.addr=%s logger.level=%s",
@@ -304,9 +350,66 @@ func QuickPostgresConfig() (*DatabaseConfig, error) {
 	}
 
 	return &DatabaseConfig{
-		Type:        "postgres",
-		URL:         url,
-		MaxRetries:  maxRetries,
-		MaxPoolSize: maxPool,
+		Type:                  "postgres",
+		URL:                   url,
+		MaxRetries:            maxRetries,
+		MaxPoolSize:           maxPool,
+		MaxIdle:               viper.GetInt("DATABASE_MAX_IDLE"),
+		ConnMaxLifetime:       viper.GetDuration("DATABASE_CONN_MAX_LIFETIME"),
+		ConnMaxIdleTime:       viper.GetDuration("DATABASE_CONN_MAX_IDLE_TIME"),
+		ConnectTimeout:        viper.GetDuration("DATABASE_CONNECT_TIMEOUT"),
+		QueryTimeout:          viper.GetDuration("DATABASE_QUERY_TIMEOUT"),
+		SlowQueryThreshold:    viper.GetDuration("DATABASE_SLOW_QUERY_THRESHOLD"),
+		StmtCacheSize:         viper.GetInt("DATABASE_STMT_CACHE_SIZE"),
+		Replicas:              splitList(viper.GetString("DATABASE_REPLICAS")),
+		ReplicaStrategy:       viper.GetString("DATABASE_REPLICA_STRATEGY"),
+		ReplicaHealthInterval: viper.GetDuration("DATABASE_REPLICA_HEALTH_INTERVAL"),
+	}, nil
+}
+
+// QuickMySQLConfig loads MySQL config from MYSQL_URL or MYSQL_DSN, falling back to DATABASE_URL
+func QuickMySQLConfig() (*DatabaseConfig, error) {
+	viper.AutomaticEnv()
+	url := firstNonEmpty(
+		viper.GetString("MYSQL_URL"),
+		viper.GetString("MYSQL_DSN"),
+		viper.GetString("DATABASE_URL"),
+	)
+
+	if url == "" {
+		return nil, fmt.Errorf("MYSQL_URL, MYSQL_DSN or DATABASE_URL environment variable is required")
+	}
+
+	maxRetries := viper.GetInt("MYSQL_MAX_RETRIES")
+	if maxRetries == 0 {
+		maxRetries = 3
+	}
+
+	return &DatabaseConfig{
+		Type:               "mysql",
+		URL:                url,
+		MaxRetries:         maxRetries,
+		MaxPoolSize:        viper.GetInt("MYSQL_MAX_POOL_SIZE"),
+		MaxIdle:            viper.GetInt("MYSQL_MAX_IDLE"),
+		ConnMaxLifetime:    viper.GetDuration("MYSQL_CONN_MAX_LIFETIME"),
+		ConnMaxIdleTime:    viper.GetDuration("MYSQL_CONN_MAX_IDLE_TIME"),
+		ConnectTimeout:     viper.GetDuration("MYSQL_CONNECT_TIMEOUT"),
+		QueryTimeout:       viper.GetDuration("MYSQL_QUERY_TIMEOUT"),
+		SlowQueryThreshold: viper.GetDuration("MYSQL_SLOW_QUERY_THRESHOLD"),
+		StmtCacheSize:      viper.GetInt("MYSQL_STMT_CACHE_SIZE"),
+	}, nil
+}
+
+// QuickSQLiteConfig loads SQLite config from SQLITE_PATH or SQLITE_URL, defaulting to in-memory
+func QuickSQLiteConfig() (*DatabaseConfig, error) {
+	viper.AutomaticEnv()
+
+	return &DatabaseConfig{
+		Type:               "sqlite",
+		URL:                viper.GetString("SQLITE_URL"),
+		Database:           firstNonEmpty(viper.GetString("SQLITE_PATH"), ":memory:"),
+		MaxRetries:         3,
+		QueryTimeout:       viper.GetDuration("SQLITE_QUERY_TIMEOUT"),
+		SlowQueryThreshold: viper.GetDuration("SQLITE_SLOW_QUERY_THRESHOLD"),
 	}, nil
 }
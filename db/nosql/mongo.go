@@ -64,6 +64,11 @@ func (m *MongoDBClient) Delete(filter interface{}) error {
 	return err
 }
 
+// Database returns the client's database handle, e.g. for running commands.
+func (m *MongoDBClient) Database() *mongo.Database {
+	return m.client.Database(m.database)
+}
+
 func (m *MongoDBClient) Close() error {
 	return m.client.Disconnect(context.Background())
 }
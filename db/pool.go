@@ -3,43 +3,117 @@ package db
 import (
 	"context"
 	"database/sql"
-	"sync"
+	"fmt"
 	"time"
+
+	"github.com/yoockh/dbyoc/config"
+	sqlpkg "github.com/yoockh/dbyoc/db/sql"
 )
 
+// DBPool wraps a driver-agnostic *sql.DB connection pool.
 type DBPool struct {
 	pool        *sql.DB
+	driver      string
 	maxOpen     int
 	maxIdle     int
-	idleTimeout time.Duration
-	mu          sync.Mutex
+	maxLifetime time.Duration
+	maxIdleTime time.Duration
 }
 
-func NewDBPool(dataSourceName string, maxOpen, maxIdle int, idleTimeout time.Duration) (*DBPool, error) {
-	db, err := sql.Open("postgres", dataSourceName) // Change to "mysql" for MySQL
+// NewDBPool opens a pool for driverName ("postgres", "mysql", "sqlite", ...).
+// maxLifetime bounds how long a connection is reused at all, maxIdleTime how
+// long it may sit idle in the pool; zero disables either limit.
+func NewDBPool(driverName, dataSourceName string, maxOpen, maxIdle int, maxLifetime, maxIdleTime time.Duration) (*DBPool, error) {
+	driver := sqlpkg.NormalizeDriver(driverName)
+	db, err := sql.Open(driver, dataSourceName)
 	if err != nil {
 		return nil, err
 	}
 
 	db.SetMaxOpenConns(maxOpen)
 	db.SetMaxIdleConns(maxIdle)
-	db.SetConnMaxLifetime(idleTimeout)
+	db.SetConnMaxLifetime(maxLifetime)
+	db.SetConnMaxIdleTime(maxIdleTime)
 
 	return &DBPool{
 		pool:        db,
+		driver:      driver,
 		maxOpen:     maxOpen,
 		maxIdle:     maxIdle,
-		idleTimeout: idleTimeout,
+		maxLifetime: maxLifetime,
+		maxIdleTime: maxIdleTime,
 	}, nil
 }
 
-func (p *DBPool) GetConnection() (*sql.Conn, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// NewDBPoolFromConfig opens a pool using the driver, DSN and pool settings in cfg.
+func NewDBPoolFromConfig(cfg config.DatabaseConfig) (*DBPool, error) {
+	dbConfig, err := sqlpkg.FromDatabaseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if dbConfig.Driver == "" {
+		return nil, fmt.Errorf("database.type is required")
+	}
 
+	dsn, err := sqlpkg.BuildDSN(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDBPool(dbConfig.Driver, dsn, cfg.MaxPoolSize, cfg.MaxIdle, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime)
+}
+
+// GetConnection checks out a dedicated connection. The caller must Close it to
+// return it to the pool; prefer WithConn.
+func (p *DBPool) GetConnection() (*sql.Conn, error) {
 	return p.pool.Conn(context.Background())
 }
 
+// Conn checks out a dedicated connection, honouring ctx while waiting for one.
+func (p *DBPool) Conn(ctx context.Context) (*sql.Conn, error) {
+	return p.pool.Conn(ctx)
+}
+
+// WithConn runs fn with a dedicated connection and always returns the
+// connection to the pool afterwards, even if fn panics.
+func (p *DBPool) WithConn(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := p.pool.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	return fn(conn)
+}
+
+// SetConnMaxLifetime sets the maximum amount of time a connection may be reused.
+func (p *DBPool) SetConnMaxLifetime(d time.Duration) {
+	p.maxLifetime = d
+	p.pool.SetConnMaxLifetime(d)
+}
+
+// SetConnMaxIdleTime sets the maximum amount of time a connection may be idle.
+func (p *DBPool) SetConnMaxIdleTime(d time.Duration) {
+	p.maxIdleTime = d
+	p.pool.SetConnMaxIdleTime(d)
+}
+
+// Driver returns the normalized driver name of the pool.
+func (p *DBPool) Driver() string {
+	return p.driver
+}
+
+// DB returns the underlying *sql.DB.
+func (p *DBPool) DB() *sql.DB {
+	return p.pool
+}
+
 func (p *DBPool) Close() error {
 	return p.pool.Close()
 }
+
+// Stats returns the pool's sql.DBStats, so a DBPool can be registered with
+// metrics.PoolPoller.
+func (p *DBPool) Stats() sql.DBStats {
+	return p.pool.Stats()
+}
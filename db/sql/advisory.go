@@ -0,0 +1,193 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// AdvisoryKey hashes name to an int64 advisory lock key (64-bit FNV-1a), so
+// locks can be named instead of numbered.
+func AdvisoryKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// advisoryKey accepts an integer key or a string hashed with AdvisoryKey.
+func advisoryKey(key interface{}) (int64, error) {
+	switch k := key.(type) {
+	case int64:
+		return k, nil
+	case int:
+		return int64(k), nil
+	case int32:
+		return int64(k), nil
+	case string:
+		return AdvisoryKey(k), nil
+	default:
+		return 0, fmt.Errorf("unsupported advisory lock key type %T", key)
+	}
+}
+
+// AdvisoryLockHandle is a held PostgreSQL advisory lock. The lock lives on a
+// dedicated connection that stays checked out of the pool until Unlock.
+type AdvisoryLockHandle struct {
+	key  int64
+	conn *sql.Conn
+	tx   *sql.Tx
+
+	mu       sync.Mutex
+	released bool
+}
+
+// Key returns the int64 lock key.
+func (h *AdvisoryLockHandle) Key() int64 {
+	return h.key
+}
+
+// Conn returns the connection holding the lock, for work that must run in the
+// same session.
+func (h *AdvisoryLockHandle) Conn() *sql.Conn {
+	return h.conn
+}
+
+// Tx returns the transaction holding a transaction-scoped lock, or nil for a
+// session lock.
+func (h *AdvisoryLockHandle) Tx() *sql.Tx {
+	return h.tx
+}
+
+// Unlock releases the lock and returns the connection to the pool. For a
+// transaction-scoped lock it commits the transaction. Calling Unlock more than
+// once is a no-op.
+func (h *AdvisoryLockHandle) Unlock(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.released {
+		return nil
+	}
+	h.released = true
+
+	if h.tx != nil {
+		defer h.conn.Close()
+		if err := h.tx.Commit(); err != nil {
+			return fmt.Errorf("failed to release advisory lock %d: %w", h.key, err)
+		}
+		return nil
+	}
+
+	var unlocked bool
+	err := h.conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", h.key).Scan(&unlocked)
+	if err != nil {
+		// Don't hand a connection that may still hold the lock back to the pool.
+		discardConn(h.conn)
+		return fmt.Errorf("failed to release advisory lock %d: %w", h.key, err)
+	}
+	h.conn.Close()
+	if !unlocked {
+		return fmt.Errorf("advisory lock %d was not held", h.key)
+	}
+	return nil
+}
+
+// AdvisoryLock blocks until it acquires the session-level advisory lock for
+// key (an integer, or a string hashed with AdvisoryKey) or ctx is done.
+func (p *PostgresClient) AdvisoryLock(ctx context.Context, key interface{}) (*AdvisoryLockHandle, error) {
+	h, _, err := p.sessionLock(ctx, key, "SELECT pg_advisory_lock($1)", false)
+	return h, err
+}
+
+// TryAdvisoryLock acquires the session-level advisory lock for key without
+// waiting. It returns a nil handle and false if another session holds it.
+func (p *PostgresClient) TryAdvisoryLock(ctx context.Context, key interface{}) (*AdvisoryLockHandle, bool, error) {
+	return p.sessionLock(ctx, key, "SELECT pg_try_advisory_lock($1)", true)
+}
+
+// AdvisoryXactLock begins a transaction and blocks until it acquires a
+// transaction-scoped advisory lock for key. Run work through the handle's Tx;
+// Unlock commits, and rolling back the Tx also releases the lock.
+func (p *PostgresClient) AdvisoryXactLock(ctx context.Context, key interface{}) (*AdvisoryLockHandle, error) {
+	h, _, err := p.xactLock(ctx, key, "SELECT pg_advisory_xact_lock($1)", false)
+	return h, err
+}
+
+// TryAdvisoryXactLock is the non-blocking form of AdvisoryXactLock. It returns
+// a nil handle and false if another session holds the lock.
+func (p *PostgresClient) TryAdvisoryXactLock(ctx context.Context, key interface{}) (*AdvisoryLockHandle, bool, error) {
+	return p.xactLock(ctx, key, "SELECT pg_try_advisory_xact_lock($1)", true)
+}
+
+func (p *PostgresClient) sessionLock(ctx context.Context, key interface{}, query string, try bool) (*AdvisoryLockHandle, bool, error) {
+	k, err := advisoryKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Advisory locks belong to the session, so pin a primary connection.
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	acquired, err := runLockQuery(ctx, conn, query, k, try)
+	if err != nil {
+		// A canceled pg_advisory_lock may still complete server-side.
+		discardConn(conn)
+		return nil, false, fmt.Errorf("failed to acquire advisory lock %d: %w", k, err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+	return &AdvisoryLockHandle{key: k, conn: conn}, true, nil
+}
+
+func (p *PostgresClient) xactLock(ctx context.Context, key interface{}, query string, try bool) (*AdvisoryLockHandle, bool, error) {
+	k, err := advisoryKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection: %w", err)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	acquired, err := runLockQuery(ctx, tx, query, k, try)
+	if err != nil || !acquired {
+		tx.Rollback()
+		conn.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to acquire advisory lock %d: %w", k, err)
+		}
+		return nil, false, nil
+	}
+	return &AdvisoryLockHandle{key: k, conn: conn, tx: tx}, true, nil
+}
+
+// runLockQuery runs a pg_(try_)advisory_*lock query. The blocking functions
+// return void, so only the try variants are scanned for a result.
+func runLockQuery(ctx context.Context, q Queryer, query string, key int64, try bool) (bool, error) {
+	if !try {
+		_, err := q.ExecContext(ctx, query, key)
+		return err == nil, err
+	}
+	var acquired bool
+	err := q.QueryRowContext(ctx, query, key).Scan(&acquired)
+	return acquired, err
+}
+
+// discardConn closes conn without returning it to the pool.
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	conn.Close()
+}
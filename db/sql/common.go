@@ -1,8 +1,13 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yoockh/dbyoc/config"
 )
 
 // Common types and functions for SQL database implementations
@@ -15,24 +20,124 @@ type DBConfig struct {
 	Username string
 	Password string
 	Database string
+	SSLMode  string
+	// Params holds extra driver params, e.g. connect_timeout for postgres or
+	// charset/parseTime/tls for mysql.
+	Params map[string]string
+}
+
+// FromDatabaseConfig converts a config.DatabaseConfig into a DBConfig. When URL
+// is set it is parsed and takes precedence over the individual fields.
+func FromDatabaseConfig(cfg config.DatabaseConfig) (DBConfig, error) {
+	if cfg.URL != "" {
+		parsed, err := ParseDSN(cfg.Type, cfg.URL)
+		if err != nil && cfg.Type == "" {
+			parsed, err = ParseURL(cfg.URL)
+		}
+		if err != nil {
+			return DBConfig{}, err
+		}
+		return parsed, nil
+	}
+
+	return DBConfig{
+		Driver:   NormalizeDriver(cfg.Type),
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.User,
+		Password: cfg.Password,
+		Database: cfg.Database,
+		SSLMode:  cfg.SSLMode,
+	}, nil
+}
+
+// Placeholder returns the n-th (1-based) bind placeholder for driver: $n for
+// postgres, ? for mysql and sqlite.
+func Placeholder(driver string, n int) string {
+	if NormalizeDriver(driver) == DriverPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
 }
 
 // OpenDB opens a new database connection based on the provided configuration.
 func OpenDB(config DBConfig) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", config.Username, config.Password, config.Host, config.Port, config.Database)
-	db, err := sql.Open(config.Driver, dsn)
+	dsn, err := BuildDSN(config)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	db, err := sql.Open(NormalizeDriver(config.Driver), dsn)
+	if err != nil {
 		return nil, err
 	}
 
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
 	return db, nil
 }
 
+// OpenURL opens a new database connection from a connection URL.
+func OpenURL(rawURL string) (*sql.DB, error) {
+	config, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return OpenDB(config)
+}
+
+// poolDefaults are the constructor specific fallbacks for unset pool settings.
+type poolDefaults struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+}
+
+// applyPoolSettings configures the sql.DB pool from cfg, falling back to defaults
+// for any setting left at zero.
+func applyPoolSettings(db *sql.DB, cfg config.DatabaseConfig, defaults poolDefaults) {
+	maxOpen := cfg.MaxPoolSize
+	if maxOpen <= 0 {
+		maxOpen = defaults.maxOpen
+	}
+	if maxOpen > 0 {
+		db.SetMaxOpenConns(maxOpen)
+	}
+
+	maxIdle := cfg.MaxIdle
+	if maxIdle <= 0 {
+		maxIdle = defaults.maxIdle
+	}
+	if maxIdle > 0 {
+		db.SetMaxIdleConns(maxIdle)
+	}
+
+	lifetime := cfg.ConnMaxLifetime
+	if lifetime <= 0 {
+		lifetime = defaults.maxLifetime
+	}
+	db.SetConnMaxLifetime(lifetime)
+
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+}
+
+// pingDB verifies the connection, bounded by timeout when it is set.
+func pingDB(db *sql.DB, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return db.PingContext(ctx)
+}
+
 // CloseDB closes the database connection.
 func CloseDB(db *sql.DB) error {
 	return db.Close()
-}
\ No newline at end of file
+}
@@ -0,0 +1,397 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// errStmtNotRun finishes a call whose prepared-statement retry never ran.
+var errStmtNotRun = errors.New("statement was prepared but not executed")
+
+// openDB opens a *sql.DB for the registered driver whose connections run every
+// statement through runner, so hooks also see statements made on a *sql.Tx,
+// *sql.Conn or *sql.Stmt obtained from the client.
+func openDB(driverName, dsn string, runner *queryRunner) (*sql.DB, error) {
+	// sql.Open is the only way to look a registered driver up by name.
+	probe, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := probe.Driver()
+	probe.Close()
+
+	var base driver.Connector = dsnConnector{dsn: dsn, driver: drv}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if base, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(&instrumentedConnector{base: base, runner: runner}), nil
+}
+
+// dsnConnector is the driver.Connector database/sql uses for drivers without
+// driver.DriverContext.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type instrumentedConnector struct {
+	base   driver.Connector
+	runner *queryRunner
+}
+
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, runner: c.runner}, nil
+}
+
+// Driver returns the wrapped driver, so callers can still tell which database
+// is behind the *sql.DB.
+func (c *instrumentedConnector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+func (c *instrumentedConnector) Close() error {
+	if closer, ok := c.base.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// instrumentedConn runs Exec and Query calls, and those of the statements it
+// prepares, through the runner. database/sql never uses a driver.Conn from two
+// goroutines at once, so skipped needs no locking.
+type instrumentedConn struct {
+	driver.Conn
+	runner *queryRunner
+	// skipped is a call the driver declined with driver.ErrSkip (e.g. mysql
+	// without interpolateParams). database/sql retries it as a prepared
+	// statement, which completes the call instead of starting a new one.
+	skipped *queryCall
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !canExec(c.Conn) {
+		return nil, driver.ErrSkip
+	}
+	call := c.runner.begin(ctx, query, args)
+	result, err := connExec(call.ctx, c.Conn, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		c.skipped = call
+		return nil, err
+	}
+	call.finish(rowsAffected(result, err), err)
+	call.cancel()
+	return result, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !canQuery(c.Conn) {
+		return nil, driver.ErrSkip
+	}
+	call := c.runner.begin(ctx, query, args)
+	rows, err := connQuery(call.ctx, c.Conn, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		c.skipped = call
+		return nil, err
+	}
+	return call.rows(rows, err)
+}
+
+func (c *instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	pending := c.skipped
+	c.skipped = nil
+	if pending != nil && pending.query != query {
+		pending.finish(-1, errStmtNotRun)
+		pending.cancel()
+		pending = nil
+	}
+
+	var stmt driver.Stmt
+	var err error
+	if prep, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = prep.PrepareContext(ctx, query)
+	} else if err = ctx.Err(); err == nil {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		if pending != nil {
+			pending.finish(-1, err)
+			pending.cancel()
+		}
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, conn: c, query: query, pending: pending}, nil
+}
+
+func (c *instrumentedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if begin, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return begin.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support non-default isolation level or read-only transactions")
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without ConnBeginTx
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *instrumentedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// instrumentedStmt runs a prepared statement's calls through the runner.
+type instrumentedStmt struct {
+	driver.Stmt
+	conn  *instrumentedConn
+	query string
+	// pending is the declined conn call this statement retries, if any.
+	pending *queryCall
+}
+
+func (s *instrumentedStmt) call(ctx context.Context, args []driver.NamedValue) *queryCall {
+	if call := s.pending; call != nil {
+		s.pending = nil
+		return call
+	}
+	return s.conn.runner.begin(ctx, s.query, args)
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	call := s.call(ctx, args)
+	var result driver.Result
+	var err error
+	if exec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = exec.ExecContext(call.ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(call.ctx, args); err == nil {
+			result, err = s.Stmt.Exec(values) //nolint:staticcheck // fallback for drivers without StmtExecContext
+		}
+	}
+	call.finish(rowsAffected(result, err), err)
+	call.cancel()
+	return result, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	call := s.call(ctx, args)
+	var rows driver.Rows
+	var err error
+	if query, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = query.QueryContext(call.ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(call.ctx, args); err == nil {
+			rows, err = s.Stmt.Query(values) //nolint:staticcheck // fallback for drivers without StmtQueryContext
+		}
+	}
+	return call.rows(rows, err)
+}
+
+func (s *instrumentedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamed(args))
+}
+
+func (s *instrumentedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamed(args))
+}
+
+func (s *instrumentedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return s.conn.CheckNamedValue(nv)
+}
+
+func (s *instrumentedStmt) Close() error {
+	if call := s.pending; call != nil {
+		s.pending = nil
+		call.finish(-1, errStmtNotRun)
+		call.cancel()
+	}
+	return s.Stmt.Close()
+}
+
+// instrumentedRows releases the call's timeout when the rows are closed and
+// otherwise behaves like the driver's rows, including the optional column
+// type interfaces (with database/sql's defaults when the driver lacks them).
+type instrumentedRows struct {
+	driver.Rows
+	cancel context.CancelFunc
+}
+
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	r.cancel()
+	return err
+}
+
+func (r *instrumentedRows) HasNextResultSet() bool {
+	if next, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return next.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *instrumentedRows) NextResultSet() error {
+	if next, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return next.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *instrumentedRows) ColumnTypeScanType(index int) reflect.Type {
+	if t, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return t.ColumnTypeScanType(index)
+	}
+	return reflect.TypeFor[any]()
+}
+
+func (r *instrumentedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if t, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return t.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *instrumentedRows) ColumnTypeLength(index int) (int64, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return t.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *instrumentedRows) ColumnTypeNullable(index int) (bool, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return t.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *instrumentedRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return t.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func canExec(conn driver.Conn) bool {
+	switch conn.(type) {
+	case driver.ExecerContext, driver.Execer: //nolint:staticcheck // Execer is the pre-context fallback
+		return true
+	}
+	return false
+}
+
+func canQuery(conn driver.Conn) bool {
+	switch conn.(type) {
+	case driver.QueryerContext, driver.Queryer: //nolint:staticcheck // Queryer is the pre-context fallback
+		return true
+	}
+	return false
+}
+
+func connExec(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) (driver.Result, error) {
+	if exec, ok := conn.(driver.ExecerContext); ok {
+		return exec.ExecContext(ctx, query, args)
+	}
+	values, err := namedToValues(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(driver.Execer).Exec(query, values) //nolint:staticcheck // checked by canExec
+}
+
+func connQuery(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	values, err := namedToValues(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(driver.Queryer).Query(query, values) //nolint:staticcheck // checked by canQuery
+}
+
+// namedToValues converts args for drivers without context support, which
+// cannot take named parameters.
+func namedToValues(ctx context.Context, args []driver.NamedValue) ([]driver.Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func valuesToNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, value := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: value}
+	}
+	return named
+}
+
+func rowsAffected(result driver.Result, err error) int64 {
+	if err != nil || result == nil {
+		return -1
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,310 @@
+package sql
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// RowSource supplies rows for CopyFrom. Next advances to the next row and
+// returns false when the source is exhausted or failed; Err reports the failure.
+type RowSource interface {
+	Next() bool
+	Values() ([]interface{}, error)
+	Err() error
+}
+
+// ColumnMapping maps file column names to table column names for the CSV and
+// JSON-lines loaders. A nil mapping copies every file column to the table
+// column with the same name.
+type ColumnMapping map[string]string
+
+// CopyFrom streams rows from src into table using COPY FROM STDIN inside a
+// transaction and returns the number of rows copied. On failure the
+// transaction is rolled back, so nothing is copied and the count is 0; the
+// error names the failing row. table may be schema qualified ("schema.table").
+func (p *PostgresClient) CopyFrom(ctx context.Context, table string, columns []string, src RowSource) (int64, error) {
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin copy transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var copyStmt string
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		copyStmt = pq.CopyInSchema(schema, name, columns...)
+	} else {
+		copyStmt = pq.CopyIn(table, columns...)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, copyStmt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare copy into %s: %w", table, err)
+	}
+	defer stmt.Close()
+
+	var count int64
+	for src.Next() {
+		if err := ctx.Err(); err != nil {
+			return 0, fmt.Errorf("copy canceled before row %d: %w", count+1, err)
+		}
+		values, err := src.Values()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read row %d: %w", count+1, err)
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return 0, fmt.Errorf("failed to copy row %d: %w", count+1, err)
+		}
+		count++
+	}
+	if err := src.Err(); err != nil {
+		return 0, fmt.Errorf("row source failed after %d rows: %w", count, err)
+	}
+
+	// An Exec without args flushes the buffered rows.
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, fmt.Errorf("failed to flush copy into %s: %w", table, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finish copy into %s: %w", table, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit copy into %s: %w", table, err)
+	}
+
+	return count, nil
+}
+
+// CopyFromCSV copies a CSV stream with a header row into table. Empty fields
+// are copied as NULL.
+func (p *PostgresClient) CopyFromCSV(ctx context.Context, table string, r io.Reader, mapping ColumnMapping) (int64, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	var indexes []int
+	var columns []string
+	for i, name := range header {
+		column, ok := mapColumn(mapping, strings.TrimSpace(name))
+		if !ok {
+			continue
+		}
+		indexes = append(indexes, i)
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("no csv columns match the column mapping")
+	}
+
+	return p.CopyFrom(ctx, table, columns, &csvSource{reader: reader, indexes: indexes})
+}
+
+// CopyFromJSONLines copies a stream of newline delimited JSON objects into
+// table. With a nil mapping the columns are taken from the first object.
+// Nested objects and arrays are copied as JSON text.
+func (p *PostgresClient) CopyFromJSONLines(ctx context.Context, table string, r io.Reader, mapping ColumnMapping) (int64, error) {
+	src := &jsonLinesSource{scanner: bufio.NewScanner(r)}
+	src.scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if mapping == nil {
+		if !src.Next() {
+			if err := src.Err(); err != nil {
+				return 0, err
+			}
+			return 0, nil
+		}
+		mapping = make(ColumnMapping, len(src.current))
+		for key := range src.current {
+			mapping[key] = key
+		}
+		src.peeked = true
+	}
+
+	src.keys = make([]string, 0, len(mapping))
+	for key := range mapping {
+		src.keys = append(src.keys, key)
+	}
+	sort.Strings(src.keys)
+
+	columns := make([]string, len(src.keys))
+	for i, key := range src.keys {
+		columns[i] = mapping[key]
+	}
+
+	return p.CopyFrom(ctx, table, columns, src)
+}
+
+// RowsFromSlice returns a RowSource over an in-memory slice of rows.
+func RowsFromSlice(rows [][]interface{}) RowSource {
+	return &sliceSource{rows: rows, index: -1}
+}
+
+// RowsFromChannel returns a RowSource that reads rows from ch until it is
+// closed or ctx is canceled.
+func RowsFromChannel(ctx context.Context, ch <-chan []interface{}) RowSource {
+	return &channelSource{ctx: ctx, ch: ch}
+}
+
+// RowsFromFunc returns a RowSource backed by an iterator function. next
+// returns the following row and true, or false once there are no more rows.
+func RowsFromFunc(next func() ([]interface{}, bool, error)) RowSource {
+	return &funcSource{next: next}
+}
+
+type sliceSource struct {
+	rows  [][]interface{}
+	index int
+}
+
+func (s *sliceSource) Next() bool {
+	s.index++
+	return s.index < len(s.rows)
+}
+
+func (s *sliceSource) Values() ([]interface{}, error) { return s.rows[s.index], nil }
+func (s *sliceSource) Err() error                     { return nil }
+
+type channelSource struct {
+	ctx     context.Context
+	ch      <-chan []interface{}
+	current []interface{}
+	err     error
+}
+
+func (s *channelSource) Next() bool {
+	select {
+	case <-s.ctx.Done():
+		s.err = s.ctx.Err()
+		return false
+	case row, ok := <-s.ch:
+		s.current = row
+		return ok
+	}
+}
+
+func (s *channelSource) Values() ([]interface{}, error) { return s.current, nil }
+func (s *channelSource) Err() error                     { return s.err }
+
+type funcSource struct {
+	next    func() ([]interface{}, bool, error)
+	current []interface{}
+	err     error
+}
+
+func (s *funcSource) Next() bool {
+	row, ok, err := s.next()
+	if err != nil {
+		s.err = err
+		return false
+	}
+	s.current = row
+	return ok
+}
+
+func (s *funcSource) Values() ([]interface{}, error) { return s.current, nil }
+func (s *funcSource) Err() error                     { return s.err }
+
+type csvSource struct {
+	reader  *csv.Reader
+	indexes []int
+	record  []string
+	err     error
+}
+
+func (s *csvSource) Next() bool {
+	record, err := s.reader.Read()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		return false
+	}
+	s.record = record
+	return true
+}
+
+func (s *csvSource) Values() ([]interface{}, error) {
+	values := make([]interface{}, len(s.indexes))
+	for i, index := range s.indexes {
+		if index >= len(s.record) || s.record[index] == "" {
+			continue
+		}
+		values[i] = s.record[index]
+	}
+	return values, nil
+}
+
+func (s *csvSource) Err() error { return s.err }
+
+type jsonLinesSource struct {
+	scanner *bufio.Scanner
+	keys    []string
+	current map[string]interface{}
+	peeked  bool
+	line    int
+	err     error
+}
+
+func (s *jsonLinesSource) Next() bool {
+	if s.peeked {
+		s.peeked = false
+		return true
+	}
+	for s.scanner.Scan() {
+		s.line++
+		text := strings.TrimSpace(s.scanner.Text())
+		if text == "" {
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		s.current = nil
+		if err := decoder.Decode(&s.current); err != nil {
+			s.err = fmt.Errorf("invalid json on line %d: %w", s.line, err)
+			return false
+		}
+		return true
+	}
+	s.err = s.scanner.Err()
+	return false
+}
+
+func (s *jsonLinesSource) Values() ([]interface{}, error) {
+	values := make([]interface{}, len(s.keys))
+	for i, key := range s.keys {
+		switch v := s.current[key].(type) {
+		case nil:
+		case string, bool:
+			values[i] = v
+		case json.Number:
+			values[i] = v.String()
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode field %s on line %d: %w", key, s.line, err)
+			}
+			values[i] = string(encoded)
+		}
+	}
+	return values, nil
+}
+
+func (s *jsonLinesSource) Err() error { return s.err }
+
+// mapColumn resolves a file column to its table column.
+func mapColumn(mapping ColumnMapping, name string) (string, bool) {
+	if mapping == nil {
+		return name, true
+	}
+	column, ok := mapping[name]
+	return column, ok
+}
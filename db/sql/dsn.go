@@ -0,0 +1,407 @@
+package sql
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Supported driver names after normalization.
+const (
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+)
+
+// NormalizeDriver maps driver aliases to the names used by BuildDSN.
+func NormalizeDriver(driver string) string {
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "postgres", "postgresql", "pg", "pgx":
+		return DriverPostgres
+	case "mysql", "mariadb":
+		return DriverMySQL
+	case "sqlite", "sqlite3", "file":
+		return DriverSQLite
+	default:
+		return strings.ToLower(strings.TrimSpace(driver))
+	}
+}
+
+// BuildDSN formats a driver specific data source name for the configuration.
+func BuildDSN(config DBConfig) (string, error) {
+	switch NormalizeDriver(config.Driver) {
+	case DriverPostgres:
+		return PostgresDSN(config), nil
+	case DriverMySQL:
+		return MySQLDSN(config)
+	case DriverSQLite:
+		return SQLiteDSN(config), nil
+	default:
+		return "", fmt.Errorf("unsupported driver: %q", config.Driver)
+	}
+}
+
+// PostgresDSN formats a lib/pq key/value connection string, quoting values as needed.
+func PostgresDSN(config DBConfig) string {
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+"="+quotePostgresValue(value))
+		}
+	}
+
+	add("host", config.Host)
+	if config.Port > 0 {
+		add("port", strconv.Itoa(config.Port))
+	}
+	add("user", config.Username)
+	add("password", config.Password)
+	add("dbname", config.Database)
+	add("sslmode", config.SSLMode)
+	for _, key := range sortedKeys(config.Params) {
+		if key == "sslmode" && config.SSLMode != "" {
+			continue
+		}
+		add(key, config.Params[key])
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// PostgresURL formats a postgres:// connection URL with escaped credentials and params.
+func PostgresURL(config DBConfig) string {
+	u := url.URL{Scheme: "postgres", Host: joinHostPort(config.Host, config.Port)}
+	if config.Username != "" {
+		if config.Password != "" {
+			u.User = url.UserPassword(config.Username, config.Password)
+		} else {
+			u.User = url.User(config.Username)
+		}
+	}
+	if config.Database != "" {
+		u.Path = "/" + config.Database
+	}
+
+	query := url.Values{}
+	for key, value := range config.Params {
+		query.Set(key, value)
+	}
+	if config.SSLMode != "" {
+		query.Set("sslmode", config.SSLMode)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// MySQLDSN formats a go-sql-driver/mysql DSN. It defaults to parseTime=true and
+// charset=utf8mb4 and derives the tls param from SSLMode unless Params overrides them.
+func MySQLDSN(config DBConfig) (string, error) {
+	base := mysql.NewConfig()
+	base.User = config.Username
+	base.Passwd = config.Password
+	base.DBName = config.Database
+	if config.Host != "" {
+		base.Net = "tcp"
+		base.Addr = joinHostPort(config.Host, config.Port)
+	}
+
+	params := url.Values{}
+	params.Set("parseTime", "true")
+	params.Set("charset", "utf8mb4")
+	if tls := mysqlTLS(config.SSLMode); tls != "" {
+		params.Set("tls", tls)
+	}
+	for key, value := range config.Params {
+		params.Set(key, value)
+	}
+
+	// Re-parse so the driver validates and normalizes the params.
+	parsed, err := mysql.ParseDSN(base.FormatDSN() + "?" + params.Encode())
+	if err != nil {
+		return "", fmt.Errorf("invalid mysql dsn: %w", err)
+	}
+	return parsed.FormatDSN(), nil
+}
+
+// SQLiteDSN formats a file: URI for the database path, or :memory: when Database is empty.
+func SQLiteDSN(config DBConfig) string {
+	path := config.Database
+	if path == "" || path == ":memory:" {
+		path = ":memory:"
+	}
+	if len(config.Params) == 0 {
+		if path == ":memory:" {
+			return path
+		}
+		return "file:" + sqlitePathEscaper.Replace(path)
+	}
+
+	query := url.Values{}
+	for key, value := range config.Params {
+		query.Set(key, value)
+	}
+	return "file:" + sqlitePathEscaper.Replace(path) + "?" + query.Encode()
+}
+
+// sqlitePathEscaper percent-encodes the characters that would otherwise end
+// the path of a file: URI; SQLite decodes them when opening the file.
+var sqlitePathEscaper = strings.NewReplacer("%", "%25", "?", "%3F", "#", "%23")
+
+// ParseDSN parses a data source name for the given driver back into a DBConfig.
+func ParseDSN(driver, dsn string) (DBConfig, error) {
+	switch NormalizeDriver(driver) {
+	case DriverPostgres:
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			return ParseURL(dsn)
+		}
+		return parsePostgresKV(dsn)
+	case DriverMySQL:
+		if strings.HasPrefix(dsn, "mysql://") {
+			return ParseURL(dsn)
+		}
+		return parseMySQLDSN(dsn)
+	case DriverSQLite:
+		return parseSQLiteDSN(dsn)
+	default:
+		return DBConfig{}, fmt.Errorf("unsupported driver: %q", driver)
+	}
+}
+
+// ParseURL derives a DBConfig from a connection URL, picking the driver from its scheme.
+func ParseURL(rawURL string) (DBConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return DBConfig{}, fmt.Errorf("invalid database url: %w", err)
+	}
+
+	driver := NormalizeDriver(u.Scheme)
+	if driver == DriverSQLite {
+		return parseSQLiteDSN(rawURL)
+	}
+	if driver != DriverPostgres && driver != DriverMySQL {
+		return DBConfig{}, fmt.Errorf("unsupported database url scheme: %q", u.Scheme)
+	}
+
+	cfg := DBConfig{
+		Driver:   driver,
+		Host:     u.Hostname(),
+		Database: strings.TrimPrefix(u.Path, "/"),
+	}
+	if port := u.Port(); port != "" {
+		cfg.Port, err = strconv.Atoi(port)
+		if err != nil {
+			return DBConfig{}, fmt.Errorf("invalid port in database url: %s", port)
+		}
+	}
+	if u.User != nil {
+		cfg.Username = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+
+	for key, values := range u.Query() {
+		if len(values) == 0 {
+			continue
+		}
+		if key == "sslmode" {
+			cfg.SSLMode = values[0]
+			continue
+		}
+		if cfg.Params == nil {
+			cfg.Params = make(map[string]string)
+		}
+		cfg.Params[key] = values[0]
+	}
+
+	return cfg, nil
+}
+
+// parsePostgresKV parses a key/value connection string, honouring single-quoted values.
+func parsePostgresKV(dsn string) (DBConfig, error) {
+	cfg := DBConfig{Driver: DriverPostgres}
+	s := strings.TrimSpace(dsn)
+
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return DBConfig{}, fmt.Errorf("invalid postgres dsn: missing '=' after %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " ")
+
+		var value strings.Builder
+		if strings.HasPrefix(s, "'") {
+			i, closed := 1, false
+			for ; i < len(s); i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+					value.WriteByte(s[i])
+					continue
+				}
+				if s[i] == '\'' {
+					closed = true
+					break
+				}
+				value.WriteByte(s[i])
+			}
+			if !closed {
+				return DBConfig{}, fmt.Errorf("invalid postgres dsn: unterminated quote for %q", key)
+			}
+			s = s[i+1:]
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			value.WriteString(s[:end])
+			s = s[end:]
+		}
+		s = strings.TrimLeft(s, " ")
+
+		switch key {
+		case "host":
+			cfg.Host = value.String()
+		case "port":
+			port, err := strconv.Atoi(value.String())
+			if err != nil {
+				return DBConfig{}, fmt.Errorf("invalid postgres dsn port: %s", value.String())
+			}
+			cfg.Port = port
+		case "user":
+			cfg.Username = value.String()
+		case "password":
+			cfg.Password = value.String()
+		case "dbname":
+			cfg.Database = value.String()
+		case "sslmode":
+			cfg.SSLMode = value.String()
+		default:
+			if cfg.Params == nil {
+				cfg.Params = make(map[string]string)
+			}
+			cfg.Params[key] = value.String()
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseMySQLDSN parses a go-sql-driver/mysql DSN, keeping every param in Params.
+func parseMySQLDSN(dsn string) (DBConfig, error) {
+	parsed, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return DBConfig{}, fmt.Errorf("invalid mysql dsn: %w", err)
+	}
+
+	cfg := DBConfig{
+		Driver:   DriverMySQL,
+		Username: parsed.User,
+		Password: parsed.Passwd,
+		Database: parsed.DBName,
+	}
+	if host, port, err := net.SplitHostPort(parsed.Addr); err == nil {
+		cfg.Host = host
+		cfg.Port, _ = strconv.Atoi(port)
+	} else {
+		cfg.Host = parsed.Addr
+	}
+
+	// FormatDSN emits the normalized params, which is the simplest way to
+	// recover the ones the driver stores in unexported fields (e.g. charset).
+	formatted := parsed.FormatDSN()
+	slash := strings.LastIndexByte(formatted, '/')
+	if q := strings.IndexByte(formatted[slash:], '?'); q >= 0 {
+		query, err := url.ParseQuery(formatted[slash+q+1:])
+		if err != nil {
+			return DBConfig{}, fmt.Errorf("invalid mysql dsn params: %w", err)
+		}
+		cfg.Params = make(map[string]string, len(query))
+		for key := range query {
+			cfg.Params[key] = query.Get(key)
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseSQLiteDSN parses a plain path, :memory:, file: or sqlite:// URI. URI
+// paths are percent-decoded; plain paths are taken as is.
+func parseSQLiteDSN(dsn string) (DBConfig, error) {
+	cfg := DBConfig{Driver: DriverSQLite}
+
+	rest, isURI := dsn, false
+	for _, prefix := range []string{"sqlite3://", "sqlite://", "file:"} {
+		if strings.HasPrefix(rest, prefix) {
+			rest, isURI = strings.TrimPrefix(rest, prefix), true
+			break
+		}
+	}
+
+	path, rawQuery, _ := strings.Cut(rest, "?")
+	if isURI {
+		path, _, _ = strings.Cut(path, "#")
+		unescaped, err := url.PathUnescape(path)
+		if err != nil {
+			return DBConfig{}, fmt.Errorf("invalid sqlite dsn path: %w", err)
+		}
+		path = unescaped
+	}
+	cfg.Database = path
+	if rawQuery != "" {
+		query, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return DBConfig{}, fmt.Errorf("invalid sqlite dsn params: %w", err)
+		}
+		cfg.Params = make(map[string]string, len(query))
+		for key := range query {
+			cfg.Params[key] = query.Get(key)
+		}
+	}
+
+	return cfg, nil
+}
+
+// quotePostgresValue single-quotes a key/value DSN value when it is empty or
+// contains whitespace, quotes or backslashes.
+func quotePostgresValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\r'\\") {
+		return value
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + replacer.Replace(value) + "'"
+}
+
+// mysqlTLS maps a postgres-style sslmode onto the mysql tls param.
+func mysqlTLS(sslMode string) string {
+	switch sslMode {
+	case "prefer", "allow":
+		return "preferred"
+	case "require":
+		return "skip-verify"
+	case "verify-ca", "verify-full":
+		return "true"
+	default:
+		return ""
+	}
+}
+
+func joinHostPort(host string, port int) string {
+	if port <= 0 {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
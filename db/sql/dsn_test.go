@@ -0,0 +1,108 @@
+package sql
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDSNRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DBConfig
+	}{
+		{"postgres plain", DBConfig{Driver: DriverPostgres, Host: "localhost", Port: 5432, Username: "app", Password: "secret", Database: "app", SSLMode: "disable"}},
+		{"postgres quoted", DBConfig{Driver: DriverPostgres, Host: "db", Username: "app", Password: `it's a \ pass`, Database: "my db", Params: map[string]string{"application_name": "dbyoc worker"}}},
+		{"postgres empty password", DBConfig{Driver: DriverPostgres, Host: "db", Username: "app", Database: "app"}},
+		{"mysql special password", DBConfig{Driver: DriverMySQL, Host: "127.0.0.1", Port: 3306, Username: "root", Password: "p@ss:w/o?rd#1", Database: "app", Params: map[string]string{"charset": "utf8mb4", "parseTime": "true"}}},
+		{"sqlite path", DBConfig{Driver: DriverSQLite, Database: "/var/lib/app/data.db"}},
+		{"sqlite params", DBConfig{Driver: DriverSQLite, Database: "data.db", Params: map[string]string{"_pragma": "busy_timeout(5000)", "mode": "rwc"}}},
+		{"sqlite question mark", DBConfig{Driver: DriverSQLite, Database: "/tmp/a?b.db"}},
+		{"sqlite hash and percent", DBConfig{Driver: DriverSQLite, Database: "/tmp/a#1 100%.db", Params: map[string]string{"mode": "ro"}}},
+		{"sqlite memory", DBConfig{Driver: DriverSQLite, Database: ":memory:"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := BuildDSN(tt.config)
+			if err != nil {
+				t.Fatalf("BuildDSN: %v", err)
+			}
+			got, err := ParseDSN(tt.config.Driver, dsn)
+			if err != nil {
+				t.Fatalf("ParseDSN(%q): %v", dsn, err)
+			}
+			if !reflect.DeepEqual(got, tt.config) {
+				t.Errorf("round trip of %q:\n got %+v\nwant %+v", dsn, got, tt.config)
+			}
+		})
+	}
+}
+
+func TestPostgresURLRoundTrip(t *testing.T) {
+	tests := []DBConfig{
+		{Driver: DriverPostgres, Host: "localhost", Port: 5432, Username: "app", Password: "p@ss:w/rd?#%", Database: "app", SSLMode: "require"},
+		{Driver: DriverPostgres, Host: "::1", Port: 5433, Username: "app", Database: "app", Params: map[string]string{"connect_timeout": "5"}},
+	}
+
+	for _, config := range tests {
+		rawURL := PostgresURL(config)
+		got, err := ParseDSN(DriverPostgres, rawURL)
+		if err != nil {
+			t.Fatalf("ParseDSN(%q): %v", rawURL, err)
+		}
+		if !reflect.DeepEqual(got, config) {
+			t.Errorf("round trip of %q:\n got %+v\nwant %+v", rawURL, got, config)
+		}
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		driver string
+		dsn    string
+		want   DBConfig
+	}{
+		{"postgresql", "host=db port=5432 user=app password='a b' dbname=app", DBConfig{Driver: DriverPostgres, Host: "db", Port: 5432, Username: "app", Password: "a b", Database: "app"}},
+		{"pg", "postgresql://app@db/app?sslmode=verify-full", DBConfig{Driver: DriverPostgres, Host: "db", Username: "app", Database: "app", SSLMode: "verify-full"}},
+		{"sqlite3", "sqlite:///tmp/app.db", DBConfig{Driver: DriverSQLite, Database: "/tmp/app.db"}},
+		{"sqlite3", "file:/tmp/a%3Fb.db?mode=ro", DBConfig{Driver: DriverSQLite, Database: "/tmp/a?b.db", Params: map[string]string{"mode": "ro"}}},
+		{"sqlite3", "/tmp/100%.db", DBConfig{Driver: DriverSQLite, Database: "/tmp/100%.db"}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDSN(tt.driver, tt.dsn)
+		if err != nil {
+			t.Fatalf("ParseDSN(%q): %v", tt.dsn, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseDSN(%q):\n got %+v\nwant %+v", tt.dsn, got, tt.want)
+		}
+	}
+
+	for _, dsn := range []string{"host=db password='unterminated", "host"} {
+		if _, err := ParseDSN(DriverPostgres, dsn); err == nil {
+			t.Errorf("ParseDSN(%q) succeeded, want error", dsn)
+		}
+	}
+	if _, err := ParseDSN("oracle", "x"); err == nil {
+		t.Error("ParseDSN with unsupported driver succeeded, want error")
+	}
+}
+
+func TestSQLiteDSNOpensEscapedPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a?b#c 100%.db")
+	db, err := sql.Open("sqlite", SQLiteDSN(DBConfig{Database: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE t (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("database not created at %q: %v", path, err)
+	}
+}
@@ -0,0 +1,66 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yoockh/dbyoc/metrics"
+)
+
+// QueryHook observes every statement run on a SQL client's connections,
+// including those on a *sql.Tx, *sql.Conn or *sql.Stmt obtained from it.
+// BeforeQuery may return a derived context (e.g. carrying a trace span) which
+// is used for the call and handed to AfterQuery. Hooks run BeforeQuery in
+// registration order and AfterQuery in reverse order.
+type QueryHook interface {
+	BeforeQuery(ctx context.Context, query string, args []interface{}) context.Context
+	AfterQuery(ctx context.Context, query string, args []interface{}, duration time.Duration, err error)
+}
+
+// HookFuncs adapts plain functions to QueryHook. Either func may be nil.
+type HookFuncs struct {
+	Before func(ctx context.Context, query string, args []interface{}) context.Context
+	After  func(ctx context.Context, query string, args []interface{}, duration time.Duration, err error)
+}
+
+func (h HookFuncs) BeforeQuery(ctx context.Context, query string, args []interface{}) context.Context {
+	if h.Before == nil {
+		return ctx
+	}
+	return h.Before(ctx, query, args)
+}
+
+func (h HookFuncs) AfterQuery(ctx context.Context, query string, args []interface{}, duration time.Duration, err error) {
+	if h.After != nil {
+		h.After(ctx, query, args, duration, err)
+	}
+}
+
+// MetricsHook records the duration of every query in m.
+func MetricsHook(m *metrics.Metrics) QueryHook {
+	return HookFuncs{
+		After: func(ctx context.Context, query string, args []interface{}, duration time.Duration, err error) {
+			m.RecordQuery(duration)
+		},
+	}
+}
+
+// LoggingHook logs every query at debug level and failed queries at error
+// level. Query text is redacted and arguments are never logged.
+func LoggingHook(log *logrus.Logger) QueryHook {
+	return HookFuncs{
+		After: func(ctx context.Context, query string, args []interface{}, duration time.Duration, err error) {
+			entry := log.WithFields(logrus.Fields{
+				"query":       RedactQuery(query),
+				"args":        len(args),
+				"duration_ms": duration.Milliseconds(),
+			})
+			if err != nil {
+				entry.WithField("error", err).Error("Query failed")
+				return
+			}
+			entry.Debug("Query executed")
+		},
+	}
+}
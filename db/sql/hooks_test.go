@@ -0,0 +1,113 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yoockh/dbyoc/config"
+)
+
+// recordingHook records the query text and context of every call.
+type recordingHook struct {
+	mu      sync.Mutex
+	queries []string
+	ctxs    []context.Context
+}
+
+func (h *recordingHook) BeforeQuery(ctx context.Context, query string, args []interface{}) context.Context {
+	return ctx
+}
+
+func (h *recordingHook) AfterQuery(ctx context.Context, query string, args []interface{}, duration time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queries = append(h.queries, query)
+	h.ctxs = append(h.ctxs, ctx)
+}
+
+func (h *recordingHook) take() ([]string, []context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	queries, ctxs := h.queries, h.ctxs
+	h.queries, h.ctxs = nil, nil
+	return queries, ctxs
+}
+
+func newTestSQLite(t *testing.T, cfg config.DatabaseConfig) *SQLiteDB {
+	t.Helper()
+	db, err := NewSQLiteDB(cfg)
+	if err != nil {
+		t.Fatalf("NewSQLiteDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestHooksSeeEveryStatement(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t, config.DatabaseConfig{})
+	hook := &recordingHook{}
+	db.AddHook(hook)
+
+	if _, err := db.Exec("CREATE TABLE t (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO t VALUES (?)", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM t").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	stmt, err := db.Prepare("INSERT INTO t VALUES (?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.Exec(2); err != nil {
+		t.Fatal(err)
+	}
+	stmt.Close()
+
+	want := []string{
+		"CREATE TABLE t (id INTEGER)",
+		"INSERT INTO t VALUES (?)",
+		"SELECT COUNT(*) FROM t",
+		"INSERT INTO t VALUES (?)",
+	}
+	if got, _ := hook.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("hooked queries:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestHooksWithStmtCache(t *testing.T) {
+	db := newTestSQLite(t, config.DatabaseConfig{StmtCacheSize: 4})
+	hook := &recordingHook{}
+	db.AddHook(hook)
+
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec("SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, _ := hook.take(); len(got) != 2 {
+		t.Errorf("hooked %d calls through the statement cache, want 2: %q", len(got), got)
+	}
+}
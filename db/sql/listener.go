@@ -0,0 +1,176 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/yoockh/dbyoc/logger"
+	"github.com/yoockh/dbyoc/utils"
+)
+
+// listenerPingInterval is how long Run waits without a notification before
+// pinging the connection, so a silently dropped connection is noticed.
+const listenerPingInterval = 90 * time.Second
+
+// Notification is a payload received on a LISTEN channel.
+type Notification struct {
+	Channel string
+	Payload string
+	PID     int
+}
+
+// NotificationHandler handles a notification received on a subscribed channel.
+type NotificationHandler func(ctx context.Context, n Notification) error
+
+// JSONHandler returns a NotificationHandler that decodes the payload as JSON into T.
+func JSONHandler[T any](fn func(ctx context.Context, channel string, payload T) error) NotificationHandler {
+	return func(ctx context.Context, n Notification) error {
+		var payload T
+		if err := json.Unmarshal([]byte(n.Payload), &payload); err != nil {
+			return fmt.Errorf("failed to decode payload on channel %s: %w", n.Channel, err)
+		}
+		return fn(ctx, n.Channel, payload)
+	}
+}
+
+// Listener subscribes to PostgreSQL LISTEN/NOTIFY channels and dispatches
+// payloads to handlers. The underlying pq.Listener reconnects automatically
+// and re-issues LISTEN for every subscribed channel.
+type Listener struct {
+	listener *pq.Listener
+	backoff  *utils.Backoff
+	logger   *logrus.Logger
+
+	mu       sync.RWMutex
+	handlers map[string][]NotificationHandler
+}
+
+// NewListener creates a Listener for the given connection string. Reconnect
+// intervals are taken from backoff, or utils.NewBackoff() when nil.
+func NewListener(connStr string, backoff *utils.Backoff) *Listener {
+	if backoff == nil {
+		backoff = utils.NewBackoff()
+	}
+
+	l := &Listener{
+		backoff:  backoff,
+		logger:   logger.GetLogger(),
+		handlers: make(map[string][]NotificationHandler),
+	}
+	l.listener = pq.NewListener(connStr, backoff.InitialInterval, backoff.MaxInterval, l.onEvent)
+	return l
+}
+
+// NewListener creates a Listener sharing the client's connection settings.
+func (p *PostgresClient) NewListener(backoff *utils.Backoff) *Listener {
+	return NewListener(p.connStr, backoff)
+}
+
+// Notify sends a notification with payload on channel.
+func (p *PostgresClient) Notify(ctx context.Context, channel, payload string) error {
+	_, err := p.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	if err != nil {
+		return fmt.Errorf("failed to notify channel %s: %w", channel, err)
+	}
+	return nil
+}
+
+// Subscribe registers handler for channel, issuing LISTEN on the first
+// subscription. LISTEN is retried with backoff while the connection is down.
+func (l *Listener) Subscribe(channel string, handler NotificationHandler) error {
+	l.mu.Lock()
+	_, listening := l.handlers[channel]
+	l.handlers[channel] = append(l.handlers[channel], handler)
+	l.mu.Unlock()
+
+	if listening {
+		return nil
+	}
+
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		err := l.listener.Listen(channel)
+		if err == nil || err == pq.ErrChannelAlreadyOpen {
+			return nil
+		}
+		if l.backoff.IsElapsed(start) {
+			l.mu.Lock()
+			delete(l.handlers, channel)
+			l.mu.Unlock()
+			return fmt.Errorf("failed to listen on channel %s: %w", channel, err)
+		}
+		time.Sleep(l.backoff.GetNextInterval(attempt))
+	}
+}
+
+// Unsubscribe removes every handler for channel and issues UNLISTEN.
+func (l *Listener) Unsubscribe(channel string) error {
+	l.mu.Lock()
+	delete(l.handlers, channel)
+	l.mu.Unlock()
+
+	if err := l.listener.Unlisten(channel); err != nil && err != pq.ErrChannelNotOpen {
+		return fmt.Errorf("failed to unlisten channel %s: %w", channel, err)
+	}
+	return nil
+}
+
+// Run dispatches notifications to handlers until ctx is canceled. Handler
+// errors are logged and do not stop the loop.
+func (l *Listener) Run(ctx context.Context) error {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n, ok := <-l.listener.Notify:
+			if !ok {
+				return fmt.Errorf("listener closed")
+			}
+			// pq sends nil after a reconnect; notifications may have been missed.
+			if n == nil {
+				continue
+			}
+			l.dispatch(ctx, Notification{Channel: n.Channel, Payload: n.Extra, PID: n.BePid})
+		case <-ticker.C:
+			if err := l.listener.Ping(); err != nil {
+				l.logger.WithError(err).Warn("Listener ping failed")
+			}
+		}
+	}
+}
+
+// Close closes the listener connection.
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
+
+func (l *Listener) dispatch(ctx context.Context, n Notification) {
+	l.mu.RLock()
+	handlers := l.handlers[n.Channel]
+	l.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, n); err != nil {
+			l.logger.WithFields(logrus.Fields{"channel": n.Channel, "error": err}).Error("Notification handler failed")
+		}
+	}
+}
+
+func (l *Listener) onEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		l.logger.WithError(err).Warn("Listener disconnected, reconnecting")
+	case pq.ListenerEventReconnected:
+		l.logger.Info("Listener reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		l.logger.WithError(err).Warn("Listener reconnect attempt failed")
+	}
+}
@@ -1,37 +1,86 @@
 package sql
 
 import (
-	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/yoockh/dbyoc/config"
 )
 
 type MySQLDB struct {
-	*sql.DB
+	instrumented
 }
 
-func NewMySQLDB(dataSourceName string) (*MySQLDB, error) {
-	db, err := sql.Open("mysql", dataSourceName)
+// NewMySQLDB opens a MySQL connection. Pool settings are read from the optional
+// cfg and default to 25 open/25 idle connections with a 5 minute lifetime.
+// dataSourceName may be a driver DSN or a mysql:// URL.
+func NewMySQLDB(dataSourceName string, cfg ...config.DatabaseConfig) (*MySQLDB, error) {
+	var dbConfig config.DatabaseConfig
+	if len(cfg) > 0 {
+		dbConfig = cfg[0]
+	}
+
+	dsn, err := mysqlConnString(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	if dsn, err = mysqlConnectTimeout(dsn, dbConfig.ConnectTimeout); err != nil {
+		return nil, err
+	}
+
+	runner := newQueryRunner(queryOptionsFromConfig(dbConfig))
+	db, err := openDB("mysql", dsn, runner)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(time.Minute * 5)
+	applyPoolSettings(db, dbConfig, poolDefaults{
+		maxOpen:     25,
+		maxIdle:     25,
+		maxLifetime: 5 * time.Minute,
+	})
 
-	if err := db.Ping(); err != nil {
+	if err := pingDB(db, dbConfig.ConnectTimeout); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	return &MySQLDB{db}, nil
+	return &MySQLDB{newInstrumented(db, runner, dbConfig)}, nil
 }
 
 func (m *MySQLDB) Close() error {
-	return m.DB.Close()
+	return m.instrumented.Close()
+}
+
+// mysqlConnString converts a mysql:// URL into a driver DSN and passes other DSNs through.
+func mysqlConnString(dataSourceName string) (string, error) {
+	if !strings.HasPrefix(dataSourceName, "mysql://") {
+		return dataSourceName, nil
+	}
+	parsed, err := ParseURL(dataSourceName)
+	if err != nil {
+		return "", err
+	}
+	return MySQLDSN(parsed)
+}
+
+// mysqlConnectTimeout sets the driver's dial timeout param from timeout unless
+// the DSN already sets one, so it applies to every pooled connection.
+func mysqlConnectTimeout(dsn string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		return dsn, nil
+	}
+	parsed, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid mysql dsn: %w", err)
+	}
+	if parsed.Timeout == 0 {
+		parsed.Timeout = timeout
+	}
+	return parsed.FormatDSN(), nil
 }
 
 // Additional MySQL specific methods can be added here, such as transaction handling, etc.
@@ -0,0 +1,234 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a page cursor cannot be decoded or does
+// not match the paginator's key columns.
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// Page is one page of keyset-paginated results. The cursors are opaque,
+// URL-safe tokens; an empty cursor means there is no page in that direction.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// Paginator pages through Query using keyset ("seek") pagination on Keys
+// instead of OFFSET, so every page costs the same however deep it is:
+//
+//	users := &sqlpkg.Paginator[User]{
+//		DB:     client,
+//		Driver: "postgres",
+//		Query:  "SELECT id, name, created_at FROM users WHERE active = $1",
+//		Keys:   []string{"created_at", "id"},
+//		Scan: func(rows *sql.Rows) (User, error) {
+//			var u User
+//			return u, rows.Scan(&u.ID, &u.Name, &u.CreatedAt)
+//		},
+//		Key: func(u User) []interface{} { return []interface{}{u.CreatedAt, u.ID} },
+//	}
+//	page, err := users.Page(ctx, r.URL.Query().Get("cursor"), 20, true)
+//
+// Query must not have ORDER BY or LIMIT; it is wrapped in a subquery, so Keys
+// name columns of its result. Keys must together be unique (end with the
+// primary key) and are all sorted in the same direction.
+type Paginator[T any] struct {
+	DB     Queryer
+	Driver string
+	Query  string
+	Keys   []string
+	// Desc sorts newest/largest first.
+	Desc bool
+	// Scan reads one row into an item.
+	Scan func(rows *sql.Rows) (T, error)
+	// Key returns an item's values for Keys, in the same order.
+	Key func(item T) []interface{}
+}
+
+// pageCursor is the decoded form of a cursor token.
+type pageCursor struct {
+	Values []json.RawMessage `json:"k"`
+	Prev   bool              `json:"p,omitempty"`
+}
+
+// cursorTime marks a time.Time key so it decodes back to a time.Time rather
+// than a string, which not every driver compares correctly.
+type cursorTime struct {
+	Time time.Time `json:"$t"`
+}
+
+// Page returns up to limit items after (or, for a PrevCursor, before) cursor.
+// An empty cursor starts at the first page. args are Query's own arguments.
+func (p *Paginator[T]) Page(ctx context.Context, cursor string, limit int, args ...interface{}) (*Page[T], error) {
+	if len(p.Keys) == 0 {
+		return nil, fmt.Errorf("paginator requires at least one key column")
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("page limit must be positive, got %d", limit)
+	}
+
+	var after []interface{}
+	backward := false
+	if cursor != "" {
+		c, values, err := p.decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after, backward = values, c.Prev
+	}
+
+	query, queryArgs := p.build(after, backward, limit, args)
+	rows, err := p.DB.QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query page: %w", err)
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := p.Scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan page row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	// One extra row was requested to learn whether another page follows.
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := &Page[T]{Items: items}
+	if len(items) == 0 {
+		return page, nil
+	}
+	first, last := items[0], items[len(items)-1]
+
+	// Moving forward, a previous page exists whenever we started from a
+	// cursor; moving backward, a next page always exists.
+	hasPrev, hasNext := cursor != "" && !backward, more
+	if backward {
+		hasPrev, hasNext = more, true
+	}
+	if hasNext {
+		if page.NextCursor, err = encodeCursor(p.Key(last), false); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		if page.PrevCursor, err = encodeCursor(p.Key(first), true); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// build wraps Query with the keyset condition, ordering and limit.
+func (p *Paginator[T]) build(after []interface{}, backward bool, limit int, args []interface{}) (string, []interface{}) {
+	driver := NormalizeDriver(p.Driver)
+	// Walking backward reverses the sort; the rows are flipped back afterwards.
+	desc := p.Desc != backward
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT * FROM (%s) AS page_q", p.Query)
+
+	queryArgs := append([]interface{}{}, args...)
+	if after != nil {
+		placeholders := make([]string, len(after))
+		for i, v := range after {
+			queryArgs = append(queryArgs, v)
+			placeholders[i] = Placeholder(driver, len(queryArgs))
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		fmt.Fprintf(&b, " WHERE (%s) %s (%s)", strings.Join(p.Keys, ", "), op, strings.Join(placeholders, ", "))
+	}
+
+	order := make([]string, len(p.Keys))
+	for i, key := range p.Keys {
+		order[i] = key
+		if desc {
+			order[i] += " DESC"
+		}
+	}
+	fmt.Fprintf(&b, " ORDER BY %s LIMIT %d", strings.Join(order, ", "), limit+1)
+	return b.String(), queryArgs
+}
+
+func (p *Paginator[T]) decodeCursor(token string) (pageCursor, []interface{}, error) {
+	var c pageCursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, nil, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || len(c.Values) != len(p.Keys) {
+		return c, nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(c.Values))
+	for i, v := range c.Values {
+		var t cursorTime
+		if bytes.HasPrefix(bytes.TrimSpace(v), []byte("{")) && json.Unmarshal(v, &t) == nil {
+			values[i] = t.Time
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return c, nil, ErrInvalidCursor
+		}
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				value = i
+			} else if f, err := n.Float64(); err == nil {
+				value = f
+			}
+		}
+		values[i] = value
+	}
+	return c, values, nil
+}
+
+func encodeCursor(values []interface{}, prev bool) (string, error) {
+	c := pageCursor{Values: make([]json.RawMessage, len(values)), Prev: prev}
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok {
+			v = cursorTime{Time: t}
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode page cursor: %w", err)
+		}
+		c.Values[i] = raw
+	}
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode page cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,176 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yoockh/dbyoc/config"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   []interface{}
+	}{
+		{"int", []interface{}{42}, []interface{}{int64(42)}},
+		{"large int", []interface{}{int64(1) << 60}, []interface{}{int64(1) << 60}},
+		{"float", []interface{}{1.5}, []interface{}{1.5}},
+		{"string", []interface{}{"a{b"}, []interface{}{"a{b"}},
+		{"time and id", []interface{}{ts, int64(7)}, []interface{}{ts, int64(7)}},
+		{"nil", []interface{}{nil}, []interface{}{nil}},
+	}
+
+	for _, tt := range tests {
+		for _, prev := range []bool{false, true} {
+			token, err := encodeCursor(tt.values, prev)
+			if err != nil {
+				t.Fatalf("%s: encodeCursor: %v", tt.name, err)
+			}
+			p := &Paginator[int]{Keys: make([]string, len(tt.values))}
+			c, got, err := p.decodeCursor(token)
+			if err != nil {
+				t.Fatalf("%s: decodeCursor: %v", tt.name, err)
+			}
+			if c.Prev != prev {
+				t.Errorf("%s: prev = %v, want %v", tt.name, c.Prev, prev)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: decoded %#v, want %#v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	valid, err := encodeCursor([]interface{}{1, 2}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Paginator[int]{Keys: []string{"id"}}
+	for _, token := range []string{"not base64!", "bm90IGpzb24", valid} {
+		if _, _, err := p.decodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeCursor(%q): %v, want ErrInvalidCursor", token, err)
+		}
+	}
+}
+
+func TestPaginatorBuild(t *testing.T) {
+	tests := []struct {
+		driver   string
+		desc     bool
+		backward bool
+		want     string
+	}{
+		{DriverPostgres, false, false, "SELECT * FROM (SELECT * FROM t WHERE a = $1) AS page_q WHERE (created_at, id) > ($2, $3) ORDER BY created_at, id LIMIT 11"},
+		{DriverPostgres, true, false, "SELECT * FROM (SELECT * FROM t WHERE a = $1) AS page_q WHERE (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT 11"},
+		{DriverPostgres, false, true, "SELECT * FROM (SELECT * FROM t WHERE a = $1) AS page_q WHERE (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT 11"},
+		{DriverMySQL, true, true, "SELECT * FROM (SELECT * FROM t WHERE a = $1) AS page_q WHERE (created_at, id) > (?, ?) ORDER BY created_at, id LIMIT 11"},
+		{DriverSQLite, false, false, "SELECT * FROM (SELECT * FROM t WHERE a = $1) AS page_q WHERE (created_at, id) > (?, ?) ORDER BY created_at, id LIMIT 11"},
+	}
+
+	for _, tt := range tests {
+		p := &Paginator[int]{Driver: tt.driver, Query: "SELECT * FROM t WHERE a = $1", Keys: []string{"created_at", "id"}, Desc: tt.desc}
+		query, args := p.build([]interface{}{"x", 5}, tt.backward, 10, []interface{}{true})
+		if query != tt.want {
+			t.Errorf("build(%s, desc=%v, backward=%v):\n got %s\nwant %s", tt.driver, tt.desc, tt.backward, query, tt.want)
+		}
+		if want := []interface{}{true, "x", 5}; !reflect.DeepEqual(args, want) {
+			t.Errorf("build args = %v, want %v", args, want)
+		}
+	}
+
+	p := &Paginator[int]{Driver: DriverPostgres, Query: "SELECT * FROM t", Keys: []string{"id"}}
+	if query, _ := p.build(nil, false, 5, nil); query != "SELECT * FROM (SELECT * FROM t) AS page_q ORDER BY id LIMIT 6" {
+		t.Errorf("first page query = %s", query)
+	}
+}
+
+type scoredItem struct {
+	ID    int64
+	Score int64
+}
+
+func TestPaginatorWalk(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t, config.DatabaseConfig{})
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, score INTEGER NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	// Scores repeat so the id tie-breaker matters.
+	for id := 1; id <= 7; id++ {
+		if _, err := db.Exec("INSERT INTO items VALUES (?, ?)", id, id/2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &Paginator[scoredItem]{
+		DB:     db,
+		Driver: DriverSQLite,
+		Query:  "SELECT id, score FROM items WHERE score >= ?",
+		Keys:   []string{"score", "id"},
+		Desc:   true,
+		Scan: func(rows *sql.Rows) (scoredItem, error) {
+			var it scoredItem
+			return it, rows.Scan(&it.ID, &it.Score)
+		},
+		Key: func(it scoredItem) []interface{} { return []interface{}{it.Score, it.ID} },
+	}
+	ids := func(page *Page[scoredItem]) []int64 {
+		var out []int64
+		for _, it := range page.Items {
+			out = append(out, it.ID)
+		}
+		return out
+	}
+	check := func(page *Page[scoredItem], want []int64, hasPrev, hasNext bool) {
+		t.Helper()
+		if got := ids(page); !reflect.DeepEqual(got, want) {
+			t.Errorf("page ids = %v, want %v", got, want)
+		}
+		if (page.PrevCursor != "") != hasPrev || (page.NextCursor != "") != hasNext {
+			t.Errorf("page %v: prev %v next %v, want %v %v", want, page.PrevCursor != "", page.NextCursor != "", hasPrev, hasNext)
+		}
+	}
+
+	// score >= 0 selects every row; order is (score, id) descending:
+	// 7 6 5 4 3 2 1
+	page1, err := p.Page(ctx, "", 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(page1, []int64{7, 6, 5}, false, true)
+
+	page2, err := p.Page(ctx, page1.NextCursor, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(page2, []int64{4, 3, 2}, true, true)
+
+	page3, err := p.Page(ctx, page2.NextCursor, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(page3, []int64{1}, true, false)
+
+	back2, err := p.Page(ctx, page3.PrevCursor, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(back2, []int64{4, 3, 2}, true, true)
+
+	back1, err := p.Page(ctx, back2.PrevCursor, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(back1, []int64{7, 6, 5}, false, true)
+
+	if _, err := p.Page(ctx, "garbage", 3, 0); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Page with bad cursor: %v, want ErrInvalidCursor", err)
+	}
+}
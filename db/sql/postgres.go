@@ -1,8 +1,12 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
@@ -10,8 +14,10 @@ import (
 )
 
 type PostgresClient struct {
-	*sql.DB
+	instrumented
 	dbConfig config.DatabaseConfig
+	connStr  string
+	router   *replicaRouter
 }
 
 func NewPostgresClient(cfg config.DatabaseConfig) (*PostgresClient, error) {
@@ -19,47 +25,135 @@ func NewPostgresClient(cfg config.DatabaseConfig) (*PostgresClient, error) {
 
 	// Prioritize URL if provided
 	if cfg.URL != "" {
-		connStr = cfg.URL
+		var err error
+		connStr, err = postgresConnectTimeout(cfg.URL, cfg.ConnectTimeout)
+		if err != nil {
+			return nil, err
+		}
 	} else {
-		connStr = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database, cfg.SSLMode)
+		dbConfig := DBConfig{
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			Username: cfg.User,
+			Password: cfg.Password,
+			Database: cfg.Database,
+			SSLMode:  cfg.SSLMode,
+		}
+		// pq only accepts whole seconds for connect_timeout
+		if secs := int(cfg.ConnectTimeout.Seconds()); secs > 0 {
+			dbConfig.Params = map[string]string{"connect_timeout": strconv.Itoa(secs)}
+		}
+		connStr = PostgresDSN(dbConfig)
 	}
 
-	db, err := sql.Open("postgres", connStr)
+	runner := newQueryRunner(queryOptionsFromConfig(cfg))
+	db, err := openDB("postgres", connStr, runner)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = db.Ping(); err != nil {
+	// Set pool settings
+	applyPoolSettings(db, cfg, poolDefaults{
+		maxIdle:     cfg.MaxPoolSize / 2,
+		maxLifetime: 5 * time.Minute,
+	})
+
+	if err = pingDB(db, cfg.ConnectTimeout); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	// Set pool settings
-	if cfg.MaxPoolSize > 0 {
-		db.SetMaxOpenConns(cfg.MaxPoolSize)
-		db.SetMaxIdleConns(cfg.MaxPoolSize / 2)
+	client := &PostgresClient{
+		instrumented: newInstrumented(db, runner, cfg),
+		dbConfig:     cfg,
+		connStr:      connStr,
+	}
+	if len(cfg.Replicas) > 0 {
+		client.router, err = newReplicaRouter(db, cfg, "postgres", runner)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
-	db.SetConnMaxLifetime(5 * time.Minute)
 
-	return &PostgresClient{DB: db, dbConfig: cfg}, nil
+	return client, nil
+}
+
+// postgresConnectTimeout adds connect_timeout to a postgres:// URL or key/value
+// DSN unless it already sets one, so every pooled connection has a dial
+// timeout and not just the first ping.
+func postgresConnectTimeout(connStr string, timeout time.Duration) (string, error) {
+	// pq only accepts whole seconds for connect_timeout
+	secs := int(timeout.Seconds())
+	if secs <= 0 {
+		return connStr, nil
+	}
+
+	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+		u, err := url.Parse(connStr)
+		if err != nil {
+			return "", fmt.Errorf("invalid database url: %w", err)
+		}
+		query := u.Query()
+		if query.Get("connect_timeout") == "" {
+			query.Set("connect_timeout", strconv.Itoa(secs))
+			u.RawQuery = query.Encode()
+		}
+		return u.String(), nil
+	}
+
+	parsed, err := parsePostgresKV(connStr)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := parsed.Params["connect_timeout"]; ok {
+		return connStr, nil
+	}
+	return strings.TrimSpace(connStr) + " connect_timeout=" + strconv.Itoa(secs), nil
 }
 
 func (p *PostgresClient) Close() error {
-	return p.DB.Close()
+	if p.router != nil {
+		p.router.Close()
+	}
+	return p.instrumented.Close()
+}
+
+// reader returns the database reads should use: a healthy replica when
+// replicas are configured, otherwise the primary.
+func (p *PostgresClient) reader(ctx context.Context) *sql.DB {
+	if p.router == nil {
+		return p.DB
+	}
+	return p.router.reader(ctx)
 }
 
+// Query runs a read query, routed to a replica when replicas are configured.
+// See QueryContext.
 func (p *PostgresClient) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return p.DB.Query(query, args...)
+	return p.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext runs a read query, routed to a replica when replicas are
+// configured. Routing does not inspect the statement: Query, QueryContext and
+// RetryQuery send anything, including INSERT ... RETURNING or a locking
+// SELECT ... FOR UPDATE, to a read-only replica. Use WithReadYourWrites (or
+// Exec/QueryRow, which always use the primary) for such statements and for
+// reads that must see a preceding write.
+func (p *PostgresClient) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.runner.query(ctx, p.reader(ctx), query, args...)
 }
 
 func (p *PostgresClient) Insert(query string, args ...interface{}) (sql.Result, error) {
-	return p.DB.Exec(query, args...)
+	return p.Exec(query, args...)
 }
 
 func (p *PostgresClient) Update(query string, args ...interface{}) (sql.Result, error) {
-	return p.DB.Exec(query, args...)
+	return p.Exec(query, args...)
 }
 
+// RetryQuery runs Query up to MaxRetries times; like Query it is routed to a
+// replica when replicas are configured.
 func (p *PostgresClient) RetryQuery(query string, args ...interface{}) (*sql.Rows, error) {
 	var rows *sql.Rows
 	var err error
@@ -0,0 +1,361 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yoockh/dbyoc/config"
+	"github.com/yoockh/dbyoc/logger"
+	"github.com/yoockh/dbyoc/metrics"
+)
+
+// QueryOptions configures default query timeouts and slow-query logging for
+// the SQL clients.
+type QueryOptions struct {
+	// Timeout is applied to every statement whose context has no deadline,
+	// including those run on a *sql.Tx, *sql.Conn or *sql.Stmt from the
+	// client. For queries it is released when the rows are closed.
+	Timeout time.Duration
+	// SlowThreshold logs any statement taking at least this long at warn level.
+	SlowThreshold time.Duration
+}
+
+// queryOptionsFromConfig reads QueryOptions from the database config.
+func queryOptionsFromConfig(cfg config.DatabaseConfig) QueryOptions {
+	return QueryOptions{
+		Timeout:       cfg.QueryTimeout,
+		SlowThreshold: cfg.SlowQueryThreshold,
+	}
+}
+
+// Queryer is satisfied by *sql.DB, *sql.Tx, *sql.Conn and the SQL clients in
+// this package.
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// queryRunner applies QueryOptions and QueryHooks to every statement on the
+// client's connections (see instrumentedConn) and routes client calls through
+// the statement cache.
+type queryRunner struct {
+	mu     sync.RWMutex
+	opts   QueryOptions
+	hooks  []QueryHook
+	stmts  *stmtCache
+	logger *logrus.Logger
+}
+
+func newQueryRunner(opts QueryOptions) *queryRunner {
+	return &queryRunner{opts: opts, logger: logger.GetLogger()}
+}
+
+func (r *queryRunner) setOptions(opts QueryOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.opts = opts
+}
+
+func (r *queryRunner) addHook(hook QueryHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// copy on write so in-flight calls keep iterating their own slice
+	hooks := make([]QueryHook, len(r.hooks), len(r.hooks)+1)
+	copy(hooks, r.hooks)
+	r.hooks = append(hooks, hook)
+}
+
+func (r *queryRunner) snapshot() (QueryOptions, []QueryHook) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.opts, r.hooks
+}
+
+// setStmtCache replaces the statement cache, closing the previous one.
+func (r *queryRunner) setStmtCache(cache *stmtCache) {
+	r.mu.Lock()
+	old := r.stmts
+	r.stmts = cache
+	r.mu.Unlock()
+	if old != nil {
+		old.purge()
+	}
+}
+
+// prepared swaps q for a cached prepared statement when the cache is enabled
+// and q is the cached database. If preparing fails the call runs unprepared.
+func (r *queryRunner) prepared(ctx context.Context, q Queryer, query string) (Queryer, func(error)) {
+	r.mu.RLock()
+	cache := r.stmts
+	r.mu.RUnlock()
+
+	if db, ok := q.(*sql.DB); !ok || cache == nil || db != cache.db {
+		return q, func(error) {}
+	}
+	stmt, release, err := cache.acquire(ctx, query)
+	if err != nil {
+		return q, func(error) {}
+	}
+	return stmtQueryer{stmt: stmt}, func(err error) {
+		release()
+		if isReconnectErr(err) {
+			cache.purge()
+		}
+	}
+}
+
+func beforeQuery(ctx context.Context, hooks []QueryHook, query string, args []interface{}) context.Context {
+	for _, hook := range hooks {
+		ctx = hook.BeforeQuery(ctx, query, args)
+	}
+	return ctx
+}
+
+func afterQuery(ctx context.Context, hooks []QueryHook, query string, args []interface{}, duration time.Duration, err error) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].AfterQuery(ctx, query, args, duration, err)
+	}
+}
+
+// withTimeout applies the default timeout when ctx has no deadline.
+func (r *queryRunner) withTimeout(ctx context.Context, opts QueryOptions) (context.Context, context.CancelFunc) {
+	if opts.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, opts.Timeout)
+}
+
+// queryCall is one statement run through the runner by an instrumentedConn
+// or instrumentedStmt.
+type queryCall struct {
+	runner *queryRunner
+	opts   QueryOptions
+	hooks  []QueryHook
+	ctx    context.Context
+	cancel context.CancelFunc
+	query  string
+	args   []interface{}
+	start  time.Time
+}
+
+// begin applies the default timeout and runs BeforeQuery hooks. The caller
+// runs the statement with call.ctx, then calls finish and, once the result is
+// no longer read, cancel.
+func (r *queryRunner) begin(ctx context.Context, query string, named []driver.NamedValue) *queryCall {
+	opts, hooks := r.snapshot()
+	args := make([]interface{}, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	ctx, cancel := r.withTimeout(ctx, opts)
+	ctx = beforeQuery(ctx, hooks, query, args)
+	return &queryCall{
+		runner: r,
+		opts:   opts,
+		hooks:  hooks,
+		ctx:    ctx,
+		cancel: cancel,
+		query:  query,
+		args:   args,
+		start:  time.Now(),
+	}
+}
+
+// finish runs AfterQuery hooks and the slow-query log.
+func (c *queryCall) finish(rowsAffected int64, err error) {
+	duration := time.Since(c.start)
+	afterQuery(c.ctx, c.hooks, c.query, c.args, duration, err)
+	c.runner.observe(c.opts, c.query, duration, rowsAffected, err)
+}
+
+// rows finishes a query call. The timeout is released when the rows are
+// closed, which database/sql does after the last Next and in Row.Scan.
+func (c *queryCall) rows(rows driver.Rows, err error) (driver.Rows, error) {
+	c.finish(-1, err)
+	if err != nil {
+		c.cancel()
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, cancel: c.cancel}, nil
+}
+
+func (r *queryRunner) exec(ctx context.Context, q Queryer, query string, args ...interface{}) (sql.Result, error) {
+	target, done := r.prepared(ctx, q, query)
+	result, err := target.ExecContext(ctx, query, args...)
+	done(err)
+	return result, err
+}
+
+func (r *queryRunner) query(ctx context.Context, q Queryer, query string, args ...interface{}) (*sql.Rows, error) {
+	target, done := r.prepared(ctx, q, query)
+	rows, err := target.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+func (r *queryRunner) queryRow(ctx context.Context, q Queryer, query string, args ...interface{}) *sql.Row {
+	target, done := r.prepared(ctx, q, query)
+	row := target.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// observe logs the statement at warn level when it exceeded the slow threshold.
+func (r *queryRunner) observe(opts QueryOptions, query string, duration time.Duration, rowsAffected int64, err error) {
+	if opts.SlowThreshold <= 0 || duration < opts.SlowThreshold {
+		return
+	}
+
+	fields := logrus.Fields{
+		"query":       RedactQuery(query),
+		"duration_ms": duration.Milliseconds(),
+		"caller":      callerLocation(),
+	}
+	if rowsAffected >= 0 {
+		fields["rows_affected"] = rowsAffected
+	}
+	if err != nil {
+		fields["error"] = err
+	}
+	r.logger.WithFields(fields).Warn("Slow query")
+}
+
+var (
+	stringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numericLiteral = regexp.MustCompile(`(^|[^\w$])\d+(?:\.\d+)?\b`)
+	whitespace     = regexp.MustCompile(`\s+`)
+)
+
+// RedactQuery replaces string and numeric literals in query with "?" so logged
+// statements don't leak values inlined into the SQL. Bind parameters ($1, ?)
+// are never logged.
+func RedactQuery(query string) string {
+	redacted := stringLiteral.ReplaceAllString(query, "?")
+	redacted = numericLiteral.ReplaceAllString(redacted, "${1}?")
+	return strings.TrimSpace(whitespace.ReplaceAllString(redacted, " "))
+}
+
+// callerLocation returns the file:line of the first frame outside this
+// package and database/sql.
+func callerLocation() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "github.com/yoockh/dbyoc/db/sql.") &&
+			!strings.HasPrefix(frame.Function, "database/sql.") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// instrumented wraps a *sql.DB opened with openDB so Exec, Query and QueryRow
+// (and their Context variants) can use the client's statement cache. It is
+// embedded by the SQL clients, which keeps client.DB usable as a plain *sql.DB.
+type instrumented struct {
+	*sql.DB
+	runner *queryRunner
+}
+
+// newInstrumented wraps db, opened with openDB(..., runner), with the
+// statement cache from cfg. A cache enabled this way counts no metrics until
+// SetStmtCacheMetrics.
+func newInstrumented(db *sql.DB, runner *queryRunner, cfg config.DatabaseConfig) instrumented {
+	i := instrumented{DB: db, runner: runner}
+	if cfg.StmtCacheSize > 0 {
+		i.EnableStmtCache(cfg.StmtCacheSize, nil)
+	}
+	return i
+}
+
+// SetQueryOptions replaces the default timeout and slow-query threshold.
+func (i instrumented) SetQueryOptions(opts QueryOptions) {
+	i.runner.setOptions(opts)
+}
+
+// EnableStmtCache keeps up to size prepared statements, keyed by query text,
+// for calls on the client's primary database. Evicted statements are closed,
+// the cache is invalidated when a call reports a lost connection, and hits and
+// misses are counted in m when it is non-nil. A size <= 0 disables the cache.
+func (i instrumented) EnableStmtCache(size int, m *metrics.Metrics) {
+	if size <= 0 {
+		i.runner.setStmtCache(nil)
+		return
+	}
+	i.runner.setStmtCache(newStmtCache(i.DB, size, m))
+}
+
+// SetStmtCacheMetrics counts hits and misses of the current statement cache
+// in m, e.g. for a cache enabled through database.stmt_cache_size, which has
+// no metrics. The cache is recreated with the same size, so statements are
+// prepared again. It does nothing when the cache is disabled.
+func (i instrumented) SetStmtCacheMetrics(m *metrics.Metrics) {
+	i.runner.mu.RLock()
+	cache := i.runner.stmts
+	i.runner.mu.RUnlock()
+	if cache != nil {
+		i.runner.setStmtCache(newStmtCache(i.DB, cache.size, m))
+	}
+}
+
+// InvalidateStmtCache closes every cached prepared statement.
+func (i instrumented) InvalidateStmtCache() {
+	i.runner.mu.RLock()
+	cache := i.runner.stmts
+	i.runner.mu.RUnlock()
+	if cache != nil {
+		cache.purge()
+	}
+}
+
+// Close closes cached statements and the database.
+func (i instrumented) Close() error {
+	i.runner.setStmtCache(nil)
+	return i.DB.Close()
+}
+
+// AddHook registers a QueryHook for every subsequent statement, including those
+// run on a *sql.Tx, *sql.Conn or *sql.Stmt obtained from the client.
+func (i instrumented) AddHook(hook QueryHook) {
+	i.runner.addHook(hook)
+}
+
+func (i instrumented) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return i.runner.exec(context.Background(), i.DB, query, args...)
+}
+
+func (i instrumented) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return i.runner.exec(ctx, i.DB, query, args...)
+}
+
+func (i instrumented) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return i.runner.query(context.Background(), i.DB, query, args...)
+}
+
+func (i instrumented) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return i.runner.query(ctx, i.DB, query, args...)
+}
+
+func (i instrumented) QueryRow(query string, args ...interface{}) *sql.Row {
+	return i.runner.queryRow(context.Background(), i.DB, query, args...)
+}
+
+func (i instrumented) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return i.runner.queryRow(ctx, i.DB, query, args...)
+}
@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yoockh/dbyoc/config"
+	"github.com/yoockh/dbyoc/logger"
+)
+
+func TestQueryTimeoutReleasedOnClose(t *testing.T) {
+	db := newTestSQLite(t, config.DatabaseConfig{QueryTimeout: time.Hour})
+	hook := &recordingHook{}
+	db.AddHook(hook)
+
+	rows, err := db.Query("SELECT 1 UNION ALL SELECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ctxs := hook.take()
+	if len(ctxs) != 1 {
+		t.Fatalf("hooked %d calls, want 1", len(ctxs))
+	}
+	if _, ok := ctxs[0].Deadline(); !ok {
+		t.Fatal("query context has no default deadline")
+	}
+	if err := ctxs[0].Err(); err != nil {
+		t.Fatalf("query context done before the rows were read: %v", err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctxs[0].Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("query context after Close: %v, want context.Canceled", err)
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT 1").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if _, ctxs = hook.take(); len(ctxs) != 1 || !errors.Is(ctxs[0].Err(), context.Canceled) {
+		t.Error("QueryRow context not released after Scan")
+	}
+}
+
+func TestQueryOptionsApplyInTransactions(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t, config.DatabaseConfig{QueryTimeout: time.Hour})
+	hook := &recordingHook{}
+	db.AddHook(hook)
+
+	var buf bytes.Buffer
+	log := logger.GetLogger()
+	out := log.Out
+	log.SetOutput(&buf)
+	defer log.SetOutput(out)
+	db.SetQueryOptions(QueryOptions{Timeout: time.Hour, SlowThreshold: time.Nanosecond})
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "CREATE TABLE t (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ctxs := hook.take()
+	if len(ctxs) != 1 {
+		t.Fatalf("hooked %d calls, want 1", len(ctxs))
+	}
+	if _, ok := ctxs[0].Deadline(); !ok {
+		t.Error("transaction statement ran without the default timeout")
+	}
+	if !strings.Contains(buf.String(), "Slow query") {
+		t.Errorf("transaction statement not logged as slow: %q", buf.String())
+	}
+}
@@ -14,12 +14,22 @@ func QuickPostgres() (*PostgresClient, error) {
 	return NewPostgresClient(*cfg)
 }
 
-// QuickMySQL creates MySQL client from DATABASE_URL env only
+// QuickMySQL creates MySQL client from MYSQL_URL, MYSQL_DSN or DATABASE_URL env
 func QuickMySQL() (*MySQLDB, error) {
-	cfg, err := config.QuickPostgresConfig() // reuse for mysql
+	cfg, err := config.QuickMySQLConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	return NewMySQLDB(cfg.URL)
+	return NewMySQLDB(cfg.URL, *cfg)
+}
+
+// QuickSQLite creates SQLite client from SQLITE_PATH or SQLITE_URL env, in-memory when unset
+func QuickSQLite() (*SQLiteDB, error) {
+	cfg, err := config.QuickSQLiteConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSQLiteDB(*cfg)
 }
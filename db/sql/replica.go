@@ -0,0 +1,181 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yoockh/dbyoc/config"
+	"github.com/yoockh/dbyoc/logger"
+)
+
+// Replica routing strategies for config.DatabaseConfig.ReplicaStrategy.
+const (
+	ReplicaRoundRobin       = "round_robin"
+	ReplicaLeastConnections = "least_connections"
+)
+
+const defaultReplicaHealthInterval = 10 * time.Second
+
+type primaryKey struct{}
+
+// WithReadYourWrites returns a context that routes reads to the primary, so a
+// query issued right after a write sees it regardless of replication lag.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	forced, _ := ctx.Value(primaryKey{}).(bool)
+	return forced
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaRouter picks a healthy replica for reads and falls back to the primary.
+type replicaRouter struct {
+	primary  *sql.DB
+	replicas []*replica
+	strategy string
+	interval time.Duration
+	timeout  time.Duration
+	next     atomic.Uint64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newReplicaRouter opens every replica in cfg.Replicas with the primary's pool
+// settings, connect timeout and query runner and starts the background health checker. A replica that is down at
+// startup is kept and retried by the health checker rather than failing.
+func newReplicaRouter(primary *sql.DB, cfg config.DatabaseConfig, driver string, runner *queryRunner) (*replicaRouter, error) {
+	r := &replicaRouter{
+		primary:  primary,
+		strategy: cfg.ReplicaStrategy,
+		interval: cfg.ReplicaHealthInterval,
+		timeout:  cfg.ConnectTimeout,
+		stop:     make(chan struct{}),
+	}
+	if r.strategy == "" {
+		r.strategy = ReplicaRoundRobin
+	}
+	if r.interval <= 0 {
+		r.interval = defaultReplicaHealthInterval
+	}
+	if r.timeout <= 0 {
+		r.timeout = 5 * time.Second
+	}
+
+	for _, url := range cfg.Replicas {
+		if driver == "postgres" {
+			var err error
+			if url, err = postgresConnectTimeout(url, cfg.ConnectTimeout); err != nil {
+				r.closeReplicas()
+				return nil, err
+			}
+		}
+		db, err := openDB(driver, url, runner)
+		if err != nil {
+			r.closeReplicas()
+			return nil, err
+		}
+		applyPoolSettings(db, cfg, poolDefaults{
+			maxIdle:     cfg.MaxPoolSize / 2,
+			maxLifetime: 5 * time.Minute,
+		})
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+
+	r.checkHealth()
+	r.wg.Add(1)
+	go r.healthLoop()
+
+	return r, nil
+}
+
+// reader returns the database a read should go to.
+func (r *replicaRouter) reader(ctx context.Context) *sql.DB {
+	if usePrimary(ctx) {
+		return r.primary
+	}
+
+	switch r.strategy {
+	case ReplicaLeastConnections:
+		var best *replica
+		bestInUse := 0
+		for _, rep := range r.replicas {
+			if !rep.healthy.Load() {
+				continue
+			}
+			inUse := rep.db.Stats().InUse
+			if best == nil || inUse < bestInUse {
+				best, bestInUse = rep, inUse
+			}
+		}
+		if best != nil {
+			return best.db
+		}
+	default:
+		n := uint64(len(r.replicas))
+		start := r.next.Add(1)
+		for i := uint64(0); i < n; i++ {
+			rep := r.replicas[(start+i)%n]
+			if rep.healthy.Load() {
+				return rep.db
+			}
+		}
+	}
+
+	return r.primary
+}
+
+func (r *replicaRouter) healthLoop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.checkHealth()
+		}
+	}
+}
+
+func (r *replicaRouter) checkHealth() {
+	for i, rep := range r.replicas {
+		err := pingDB(rep.db, r.timeout)
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				logger.Log.WithField("replica", i).Info("Replica is healthy")
+			} else {
+				logger.Log.WithField("replica", i).WithError(err).Warn("Replica is unhealthy, routing reads elsewhere")
+			}
+		}
+	}
+}
+
+func (r *replicaRouter) closeReplicas() error {
+	var firstErr error
+	for _, rep := range r.replicas {
+		if err := rep.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close stops the health checker and closes every replica connection.
+func (r *replicaRouter) Close() error {
+	close(r.stop)
+	r.wg.Wait()
+	return r.closeReplicas()
+}
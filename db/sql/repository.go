@@ -0,0 +1,385 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	// ErrNotFound is returned when no (non-deleted) row matches.
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned by Update when the row's version changed since it was read.
+	ErrConflict = errors.New("record was modified concurrently")
+)
+
+// Repository provides CRUD for the struct type T, mapped to table via `db`
+// struct tags:
+//
+//	type User struct {
+//		ID        int64      `db:"id,pk"`
+//		Name      string     `db:"name"`
+//		Version   int64      `db:"version,version"`
+//		DeletedAt *time.Time `db:"deleted_at,soft_delete"`
+//	}
+//
+// The "pk" field identifies rows; a zero pk on Create is left to the database.
+// A "version" field enables optimistic locking: Update only succeeds when the
+// stored version matches and increments it, otherwise it returns ErrConflict.
+// A "soft_delete" field makes Delete set a timestamp instead of removing the
+// row, and rows with it set are filtered out of Get and List. Untagged
+// exported fields map to their snake_case name; `db:"-"` skips a field. The
+// fields of an untagged embedded struct are mapped as if declared in T.
+type Repository[T any] struct {
+	db      Queryer
+	driver  string
+	table   string
+	fields  []repoField
+	pk      *repoField
+	version *repoField
+	deleted *repoField
+}
+
+type repoField struct {
+	column string
+	index  []int
+}
+
+// NewRepository creates a Repository for T on table. driver selects the
+// placeholder style ("postgres", "mysql" or "sqlite").
+func NewRepository[T any](db Queryer, driver, table string) (*Repository[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("repository type must be a struct, got %s", typ)
+	}
+
+	r := &Repository[T]{db: db, driver: NormalizeDriver(driver), table: table}
+	var tags fieldTags
+	if err := r.addFields(typ, nil, &tags); err != nil {
+		return nil, err
+	}
+	r.pk, r.version, r.deleted = r.lookup(tags.pk), r.lookup(tags.version), r.lookup(tags.deleted)
+
+	if r.pk == nil {
+		return nil, fmt.Errorf("repository type %s has no field tagged pk", typ)
+	}
+	if r.version != nil {
+		switch typ.FieldByIndex(r.version.index).Type.Kind() {
+		case reflect.Int, reflect.Int32, reflect.Int64:
+		default:
+			return nil, fmt.Errorf("version field %s must be an integer", r.version.column)
+		}
+	}
+	return r, nil
+}
+
+// Get returns the row with the given primary key, or ErrNotFound.
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s%s",
+		r.columnList(), r.table, r.pk.column, Placeholder(r.driver, 1), r.notDeleted(" AND "))
+
+	var item T
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(r.scanTargets(&item)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get %s: %w", r.table, err)
+	}
+	return &item, nil
+}
+
+// List returns every row matching where (which may be empty). where is
+// written with the dialect's own placeholders, e.g. "status = $1".
+func (r *Repository[T]) List(ctx context.Context, where string, args ...interface{}) ([]T, error) {
+	var conditions []string
+	if where != "" {
+		conditions = append(conditions, "("+where+")")
+	}
+	if cond := r.notDeleted(""); cond != "" {
+		conditions = append(conditions, cond)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", r.columnList(), r.table)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + r.pk.column
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", r.table, err)
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		var item T
+		if err := rows.Scan(r.scanTargets(&item)...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", r.table, err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Create inserts item. A zero primary key is generated by the database and
+// written back to item; the version, when present, starts at 1.
+func (r *Repository[T]) Create(ctx context.Context, item *T) error {
+	v := reflect.ValueOf(item).Elem()
+	if r.version != nil {
+		v.FieldByIndex(r.version.index).SetInt(1)
+	}
+
+	pkValue := v.FieldByIndex(r.pk.index)
+	autoPK := pkValue.IsZero()
+
+	var columns, placeholders []string
+	var args []interface{}
+	for _, f := range r.fields {
+		if autoPK && f.column == r.pk.column {
+			continue
+		}
+		columns = append(columns, f.column)
+		args = append(args, v.FieldByIndex(f.index).Interface())
+		placeholders = append(placeholders, Placeholder(r.driver, len(args)))
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		r.table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	if autoPK && r.driver == DriverPostgres {
+		query += " RETURNING " + r.pk.column
+		if err := r.db.QueryRowContext(ctx, query, args...).Scan(pkValue.Addr().Interface()); err != nil {
+			return fmt.Errorf("failed to create %s: %w", r.table, err)
+		}
+		return nil
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", r.table, err)
+	}
+	if autoPK {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to read generated id for %s: %w", r.table, err)
+		}
+		switch pkValue.Kind() {
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			pkValue.SetInt(id)
+		case reflect.Uint, reflect.Uint32, reflect.Uint64:
+			pkValue.SetUint(uint64(id))
+		}
+	}
+	return nil
+}
+
+// Update writes every column of item. With a version field it only updates
+// the row when its stored version equals item's, returning ErrConflict
+// otherwise, and increments item's version on success.
+func (r *Repository[T]) Update(ctx context.Context, item *T) error {
+	v := reflect.ValueOf(item).Elem()
+
+	var sets []string
+	var args []interface{}
+	for _, f := range r.fields {
+		if f.column == r.pk.column || (r.version != nil && f.column == r.version.column) ||
+			(r.deleted != nil && f.column == r.deleted.column) {
+			continue
+		}
+		args = append(args, v.FieldByIndex(f.index).Interface())
+		sets = append(sets, fmt.Sprintf("%s = %s", f.column, Placeholder(r.driver, len(args))))
+	}
+	if r.version != nil {
+		sets = append(sets, fmt.Sprintf("%s = %s + 1", r.version.column, r.version.column))
+	}
+
+	args = append(args, v.FieldByIndex(r.pk.index).Interface())
+	where := fmt.Sprintf("%s = %s", r.pk.column, Placeholder(r.driver, len(args)))
+	if r.version != nil {
+		args = append(args, v.FieldByIndex(r.version.index).Interface())
+		where += fmt.Sprintf(" AND %s = %s", r.version.column, Placeholder(r.driver, len(args)))
+	}
+	where += r.notDeleted(" AND ")
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", r.table, strings.Join(sets, ", "), where)
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", r.table, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", r.table, err)
+	}
+	if affected == 0 {
+		// Tell a missing row apart from a stale version (or, on MySQL, a row
+		// whose values did not change).
+		if _, err := r.Get(ctx, v.FieldByIndex(r.pk.index).Interface()); err != nil {
+			return err
+		}
+		if r.version != nil {
+			return ErrConflict
+		}
+		return nil
+	}
+
+	if r.version != nil {
+		field := v.FieldByIndex(r.version.index)
+		field.SetInt(field.Int() + 1)
+	}
+	return nil
+}
+
+// Delete removes the row with the given primary key, or marks it deleted when
+// T has a soft_delete field. It returns ErrNotFound if no live row matched.
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	var query string
+	var args []interface{}
+	if r.deleted != nil {
+		query = fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = %s AND %s IS NULL",
+			r.table, r.deleted.column, Placeholder(r.driver, 1),
+			r.pk.column, Placeholder(r.driver, 2), r.deleted.column)
+		args = []interface{}{time.Now().UTC(), id}
+	} else {
+		query = fmt.Sprintf("DELETE FROM %s WHERE %s = %s", r.table, r.pk.column, Placeholder(r.driver, 1))
+		args = []interface{}{id}
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", r.table, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", r.table, err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// fieldTags collects the columns tagged pk, version and soft_delete.
+type fieldTags struct {
+	pk, version, deleted string
+}
+
+// addFields maps the fields of typ, found at index within T, to columns.
+// Untagged embedded structs contribute their own fields, except for value
+// types such as time.Time and sql.Scanner implementations.
+func (r *Repository[T]) addFields(typ reflect.Type, index []int, tags *fieldTags) error {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct {
+				return fmt.Errorf("embedded field %s in %s must not be a pointer", sf.Name, typ)
+			}
+			if ft.Kind() == reflect.Struct && !isColumnStruct(ft) {
+				if err := r.addFields(ft, fieldIndex, tags); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = toSnakeCase(sf.Name)
+		}
+		if r.lookup(name) != nil {
+			return fmt.Errorf("column %s is mapped by more than one field of %s", name, typ)
+		}
+
+		r.fields = append(r.fields, repoField{column: name, index: fieldIndex})
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "pk":
+				tags.pk = name
+			case "version":
+				tags.version = name
+			case "soft_delete":
+				tags.deleted = name
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// isColumnStruct reports whether the struct type t is stored in one column.
+func isColumnStruct(t reflect.Type) bool {
+	return t == timeType || reflect.PointerTo(t).Implements(scannerType)
+}
+
+func (r *Repository[T]) lookup(column string) *repoField {
+	if column == "" {
+		return nil
+	}
+	for i := range r.fields {
+		if r.fields[i].column == column {
+			return &r.fields[i]
+		}
+	}
+	return nil
+}
+
+func (r *Repository[T]) columnList() string {
+	columns := make([]string, len(r.fields))
+	for i, f := range r.fields {
+		columns[i] = f.column
+	}
+	return strings.Join(columns, ", ")
+}
+
+func (r *Repository[T]) scanTargets(item *T) []interface{} {
+	v := reflect.ValueOf(item).Elem()
+	targets := make([]interface{}, len(r.fields))
+	for i, f := range r.fields {
+		targets[i] = v.FieldByIndex(f.index).Addr().Interface()
+	}
+	return targets
+}
+
+// notDeleted returns the soft-delete filter prefixed by prefix, or "".
+func (r *Repository[T]) notDeleted(prefix string) string {
+	if r.deleted == nil {
+		return ""
+	}
+	return prefix + r.deleted.column + " IS NULL"
+}
+
+// toSnakeCase converts a Go field name such as UserID to user_id.
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, c := range runes {
+		if unicode.IsUpper(c) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
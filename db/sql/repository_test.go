@@ -0,0 +1,192 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yoockh/dbyoc/config"
+)
+
+type testModel struct {
+	ID        int64      `db:"id,pk"`
+	Version   int64      `db:"version,version"`
+	DeletedAt *time.Time `db:"deleted_at,soft_delete"`
+}
+
+type testUser struct {
+	testModel
+	Name      string
+	CreatedAt time.Time
+	Ignored   string `db:"-"`
+}
+
+func newTestRepository(t *testing.T) *Repository[testUser] {
+	t.Helper()
+	db := newTestSQLite(t, config.DatabaseConfig{})
+	if _, err := db.Exec(`CREATE TABLE users (
+		id INTEGER PRIMARY KEY,
+		version INTEGER NOT NULL,
+		deleted_at TIMESTAMP,
+		name TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository[testUser](db, DriverSQLite, "users")
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	return repo
+}
+
+func TestRepositoryEmbeddedFields(t *testing.T) {
+	repo := newTestRepository(t)
+	if got, want := repo.columnList(), "id, version, deleted_at, name, created_at"; got != want {
+		t.Errorf("columns = %q, want %q", got, want)
+	}
+
+	type pointerEmbed struct {
+		*testModel
+		Name string
+	}
+	if _, err := NewRepository[pointerEmbed](nil, DriverSQLite, "users"); err == nil {
+		t.Error("NewRepository with an embedded pointer succeeded, want error")
+	}
+
+	type duplicate struct {
+		testModel
+		Other int64 `db:"id"`
+	}
+	if _, err := NewRepository[duplicate](nil, DriverSQLite, "users"); err == nil {
+		t.Error("NewRepository with a duplicate column succeeded, want error")
+	}
+}
+
+func TestRepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	user := &testUser{Name: "ada", CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if user.ID == 0 || user.Version != 1 {
+		t.Fatalf("after Create: id %d version %d, want generated id and version 1", user.ID, user.Version)
+	}
+
+	got, err := repo.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Name != "ada" || !got.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("Get = %+v, want %+v", got, user)
+	}
+
+	user.Name = "grace"
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if user.Version != 2 {
+		t.Errorf("version after Update = %d, want 2", user.Version)
+	}
+
+	stale := *got
+	stale.Name = "stale"
+	if err := repo.Update(ctx, &stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("Update with stale version: %v, want ErrConflict", err)
+	}
+	if stale.Version != 1 {
+		t.Errorf("stale version changed to %d after a conflict", stale.Version)
+	}
+
+	missing := testUser{testModel: testModel{ID: 999, Version: 1}, Name: "nobody"}
+	if err := repo.Update(ctx, &missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update of missing row: %v, want ErrNotFound", err)
+	}
+
+	if got, err := repo.Get(ctx, user.ID); err != nil || got.Name != "grace" {
+		t.Errorf("Get after Update = %+v, %v", got, err)
+	}
+}
+
+func TestRepositorySoftDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	now := time.Now().UTC()
+	alive := &testUser{Name: "alive", CreatedAt: now}
+	gone := &testUser{Name: "gone", CreatedAt: now}
+	for _, u := range []*testUser{alive, gone} {
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.Delete(ctx, gone.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, gone.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete: %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Get(ctx, gone.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of soft-deleted row: %v, want ErrNotFound", err)
+	}
+	gone.Name = "revived"
+	if err := repo.Update(ctx, gone); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update of soft-deleted row: %v, want ErrNotFound", err)
+	}
+
+	users, err := repo.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != alive.ID {
+		t.Errorf("List = %+v, want only %d", users, alive.ID)
+	}
+
+	// The row is still there, only marked.
+	var deletedAt *time.Time
+	if err := repo.db.QueryRowContext(ctx, "SELECT deleted_at FROM users WHERE id = ?", gone.ID).Scan(&deletedAt); err != nil {
+		t.Fatal(err)
+	}
+	if deletedAt == nil {
+		t.Error("deleted_at not set by Delete")
+	}
+}
+
+func TestRepositoryHardDelete(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLite(t, config.DatabaseConfig{})
+	if _, err := db.Exec("CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	type tag struct {
+		ID   int64 `db:"id,pk"`
+		Name string
+	}
+	repo, err := NewRepository[tag](db, DriverSQLite, "tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := &tag{Name: "go"}
+	if err := repo.Create(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+	// Without a version field an unchanged row is not a conflict.
+	if err := repo.Update(ctx, item); err != nil {
+		t.Errorf("Update without version: %v", err)
+	}
+	if err := repo.Delete(ctx, item.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM tags").Scan(&n); err != nil || n != 0 {
+		t.Errorf("rows after Delete = %d (%v), want 0", n, err)
+	}
+	if err := repo.Delete(ctx, item.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of missing row: %v, want ErrNotFound", err)
+	}
+}
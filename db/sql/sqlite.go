@@ -0,0 +1,100 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yoockh/dbyoc/config"
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, registered as "sqlite"
+)
+
+// SQLiteDB is a SQLite client intended for local development and tests.
+type SQLiteDB struct {
+	instrumented
+	dbConfig config.DatabaseConfig
+}
+
+// NewSQLiteDB opens a SQLite database. The path is taken from cfg.URL (a plain
+// path, file: or sqlite:// URI) or cfg.Database; when both are empty an
+// in-memory database is used. Foreign keys are enabled unless the DSN already
+// specifies a pragma.
+func NewSQLiteDB(cfg config.DatabaseConfig) (*SQLiteDB, error) {
+	dbConfig := DBConfig{Driver: DriverSQLite, Database: cfg.Database}
+	if cfg.URL != "" {
+		parsed, err := parseSQLiteDSN(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		dbConfig = parsed
+	}
+	if _, ok := dbConfig.Params["_pragma"]; !ok {
+		if dbConfig.Params == nil {
+			dbConfig.Params = make(map[string]string)
+		}
+		dbConfig.Params["_pragma"] = "foreign_keys(1)"
+	}
+
+	dsn := SQLiteDSN(dbConfig)
+	runner := newQueryRunner(queryOptionsFromConfig(cfg))
+	db, err := openDB(DriverSQLite, dsn, runner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if isSQLiteMemory(dbConfig) {
+		// Every connection to :memory: gets its own empty database, so keep
+		// exactly one connection open for the lifetime of the client.
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+	} else {
+		applyPoolSettings(db, cfg, poolDefaults{maxLifetime: 5 * time.Minute})
+	}
+
+	if err := pingDB(db, cfg.ConnectTimeout); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return &SQLiteDB{
+		instrumented: newInstrumented(db, runner, cfg),
+		dbConfig:     cfg,
+	}, nil
+}
+
+func (s *SQLiteDB) Close() error {
+	return s.instrumented.Close()
+}
+
+func (s *SQLiteDB) Insert(query string, args ...interface{}) (sql.Result, error) {
+	return s.Exec(query, args...)
+}
+
+func (s *SQLiteDB) Update(query string, args ...interface{}) (sql.Result, error) {
+	return s.Exec(query, args...)
+}
+
+func (s *SQLiteDB) RetryQuery(query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	var err error
+	maxRetries := s.dbConfig.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 3
+	}
+
+	for i := 0; i < maxRetries; i++ {
+		rows, err = s.Query(query, args...)
+		if err == nil {
+			return rows, nil
+		}
+		time.Sleep(time.Duration(i) * time.Second)
+	}
+	return nil, fmt.Errorf("query failed after %d attempts: %w", maxRetries, err)
+}
+
+func isSQLiteMemory(cfg DBConfig) bool {
+	return cfg.Database == "" || strings.HasPrefix(cfg.Database, ":memory:") || cfg.Params["mode"] == "memory"
+}
@@ -0,0 +1,139 @@
+package sql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+
+	"github.com/yoockh/dbyoc/metrics"
+)
+
+// stmtCache is a bounded LRU of prepared statements keyed by query text.
+// Entries are reference counted so an evicted statement is only closed once
+// no call is using it.
+type stmtCache struct {
+	db      *sql.DB
+	size    int
+	metrics *metrics.Metrics
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type stmtEntry struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(db *sql.DB, size int, m *metrics.Metrics) *stmtCache {
+	return &stmtCache{
+		db:      db,
+		size:    size,
+		metrics: m,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// acquire returns a prepared statement for query, preparing and caching it on
+// a miss. The returned release func must be called once the call is done.
+func (c *stmtCache) acquire(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	c.mu.Lock()
+	if el, ok := c.items[query]; ok {
+		c.ll.MoveToFront(el)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		c.mu.Unlock()
+		if c.metrics != nil {
+			c.metrics.RecordStmtCacheHit()
+		}
+		return entry.stmt, c.releaser(entry), nil
+	}
+	c.mu.Unlock()
+
+	if c.metrics != nil {
+		c.metrics.RecordStmtCacheMiss()
+	}
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// another caller may have prepared the same query meanwhile
+	if el, ok := c.items[query]; ok {
+		stmt.Close()
+		c.ll.MoveToFront(el)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		return entry.stmt, c.releaser(entry), nil
+	}
+
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.size {
+		c.evict(c.ll.Back())
+	}
+	return stmt, c.releaser(entry), nil
+}
+
+func (c *stmtCache) releaser(entry *stmtEntry) func() {
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		entry.refs--
+		if entry.evicted && entry.refs == 0 {
+			entry.stmt.Close()
+		}
+	}
+}
+
+// evict removes el and closes its statement once it is no longer in use.
+// Callers must hold c.mu.
+func (c *stmtCache) evict(el *list.Element) {
+	entry := c.ll.Remove(el).(*stmtEntry)
+	delete(c.items, entry.query)
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// purge evicts every cached statement.
+func (c *stmtCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.evict(c.ll.Back())
+	}
+}
+
+// isReconnectErr reports whether err means the connection was lost, after
+// which cached statements are invalidated.
+func isReconnectErr(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone)
+}
+
+// stmtQueryer runs calls on a prepared statement, ignoring the query text.
+type stmtQueryer struct {
+	stmt *sql.Stmt
+}
+
+func (s stmtQueryer) ExecContext(ctx context.Context, _ string, args ...interface{}) (sql.Result, error) {
+	return s.stmt.ExecContext(ctx, args...)
+}
+
+func (s stmtQueryer) QueryContext(ctx context.Context, _ string, args ...interface{}) (*sql.Rows, error) {
+	return s.stmt.QueryContext(ctx, args...)
+}
+
+func (s stmtQueryer) QueryRowContext(ctx context.Context, _ string, args ...interface{}) *sql.Row {
+	return s.stmt.QueryRowContext(ctx, args...)
+}
@@ -0,0 +1,142 @@
+package sql
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/lib/pq/hstore"
+)
+
+// JSONB maps a json/jsonb column to a Go value of type T:
+//
+//	var settings sqlpkg.JSONB[Settings]
+//	row.Scan(&settings) // settings.Data is a Settings
+//
+// A SQL NULL scans to the zero value of T, and a T that marshals to JSON null
+// (e.g. a nil pointer, map or slice) is stored as SQL NULL.
+type JSONB[T any] struct {
+	Data T
+}
+
+// Scan implements sql.Scanner.
+func (j *JSONB[T]) Scan(src interface{}) error {
+	var zero T
+	j.Data = zero
+
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONB", src)
+	}
+	if err := json.Unmarshal(data, &j.Data); err != nil {
+		return fmt.Errorf("failed to decode JSONB: %w", err)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (j JSONB[T]) Value() (driver.Value, error) {
+	data, err := json.Marshal(j.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSONB: %w", err)
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	return string(data), nil
+}
+
+// MarshalJSON encodes Data, so a JSONB field serializes as its contents.
+func (j JSONB[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.Data)
+}
+
+// UnmarshalJSON decodes into Data.
+func (j *JSONB[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &j.Data)
+}
+
+// Array maps a one-dimensional PostgreSQL array column to a Go slice. T may be
+// bool, float32, float64, int32, int64, string, []byte or a type implementing
+// sql.Scanner. A SQL NULL scans to a nil slice, and a nil slice is stored as NULL.
+type Array[T any] []T
+
+// Scan implements sql.Scanner.
+func (a *Array[T]) Scan(src interface{}) error {
+	slice := (*[]T)(a)
+	if err := pq.Array(slice).(sql.Scanner).Scan(src); err != nil {
+		return fmt.Errorf("failed to scan array: %w", err)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (a Array[T]) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return pq.Array([]T(a)).(driver.Valuer).Value()
+}
+
+// HStore maps a PostgreSQL hstore column to a map. hstore values may be NULL,
+// which is represented by a nil *string. A SQL NULL scans to a nil map, and a
+// nil map is stored as NULL.
+type HStore map[string]*string
+
+// Scan implements sql.Scanner.
+func (h *HStore) Scan(src interface{}) error {
+	if src == nil {
+		*h = nil
+		return nil
+	}
+
+	// hstore.Hstore only accepts []byte
+	if s, ok := src.(string); ok {
+		src = []byte(s)
+	}
+	if _, ok := src.([]byte); !ok {
+		return fmt.Errorf("cannot scan %T into HStore", src)
+	}
+
+	var store hstore.Hstore
+	if err := store.Scan(src); err != nil {
+		return fmt.Errorf("failed to scan hstore: %w", err)
+	}
+	m := make(HStore, len(store.Map))
+	for k, v := range store.Map {
+		if v.Valid {
+			s := v.String
+			m[k] = &s
+		} else {
+			m[k] = nil
+		}
+	}
+	*h = m
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (h HStore) Value() (driver.Value, error) {
+	if h == nil {
+		return nil, nil
+	}
+	store := hstore.Hstore{Map: make(map[string]sql.NullString, len(h))}
+	for k, v := range h {
+		if v != nil {
+			store.Map[k] = sql.NullString{String: *v, Valid: true}
+		} else {
+			store.Map[k] = sql.NullString{}
+		}
+	}
+	return store.Value()
+}
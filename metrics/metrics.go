@@ -11,10 +11,13 @@ type Metrics struct {
 	ActiveConnections int
 	QueryCount        int
 	TotalQueryTime    time.Duration
+	StmtCacheHits     int
+	StmtCacheMisses   int
+	pools             map[string]PoolMetrics
 }
 
 func NewMetrics() *Metrics {
-	return &Metrics{}
+	return &Metrics{pools: make(map[string]PoolMetrics)}
 }
 
 func (m *Metrics) IncrementConnections() {
@@ -41,4 +44,46 @@ func (m *Metrics) GetMetrics() (int, int, int, time.Duration) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	return m.TotalConnections, m.ActiveConnections, m.QueryCount, m.TotalQueryTime
-}
\ No newline at end of file
+}
+
+// RecordPoolStats stores the latest pool sample for the named client.
+func (m *Metrics) RecordPoolStats(name string, stats PoolMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pools == nil {
+		m.pools = make(map[string]PoolMetrics)
+	}
+	m.pools[name] = stats
+}
+
+// GetPoolStats returns a copy of the latest pool sample for every client.
+func (m *Metrics) GetPoolStats() map[string]PoolMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]PoolMetrics, len(m.pools))
+	for name, stats := range m.pools {
+		out[name] = stats
+	}
+	return out
+}
+
+// RecordStmtCacheHit counts a prepared statement served from cache.
+func (m *Metrics) RecordStmtCacheHit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.StmtCacheHits++
+}
+
+// RecordStmtCacheMiss counts a statement that had to be prepared.
+func (m *Metrics) RecordStmtCacheMiss() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.StmtCacheMisses++
+}
+
+// GetStmtCacheStats returns the prepared statement cache hits and misses.
+func (m *Metrics) GetStmtCacheStats() (int, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.StmtCacheHits, m.StmtCacheMisses
+}
@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yoockh/dbyoc/logger"
+)
+
+// StatsProvider is anything exposing sql.DBStats: *sql.DB and every SQL
+// client that embeds it, or db.DBPool.
+type StatsProvider interface {
+	Stats() sql.DBStats
+}
+
+// PoolThresholds configures when PoolPoller logs saturation warnings. A zero
+// value disables the corresponding check.
+type PoolThresholds struct {
+	// MaxWaitDuration is the wait time accumulated within one poll interval
+	// above which a warning is logged.
+	MaxWaitDuration time.Duration
+	// MaxInUseRatio is the in-use/max-open ratio (0..1) above which a warning
+	// is logged. Ignored for pools without a max open limit.
+	MaxInUseRatio float64
+}
+
+// PoolPoller periodically samples the pool stats of registered SQL clients,
+// records them in Metrics and warns when a pool looks saturated.
+type PoolPoller struct {
+	metrics    *Metrics
+	interval   time.Duration
+	thresholds PoolThresholds
+	logger     *logrus.Logger
+
+	mu      sync.Mutex
+	sources map[string]StatsProvider
+	last    map[string]sql.DBStats
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPoolPoller creates a poller sampling every interval (15s when zero).
+func NewPoolPoller(m *Metrics, interval time.Duration, thresholds PoolThresholds) *PoolPoller {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	return &PoolPoller{
+		metrics:    m,
+		interval:   interval,
+		thresholds: thresholds,
+		logger:     logger.GetLogger(),
+		sources:    make(map[string]StatsProvider),
+		last:       make(map[string]sql.DBStats),
+	}
+}
+
+// Register adds a named pool to sample.
+func (p *PoolPoller) Register(name string, src StatsProvider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sources[name] = src
+}
+
+// Unregister stops sampling the named pool.
+func (p *PoolPoller) Unregister(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sources, name)
+	delete(p.last, name)
+}
+
+// Start samples in the background until ctx is canceled or Stop is called.
+func (p *PoolPoller) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.Poll()
+			}
+		}
+	}()
+}
+
+// Stop halts background sampling and waits for it to finish.
+func (p *PoolPoller) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+// Poll takes one sample of every registered pool.
+func (p *PoolPoller) Poll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for name, src := range p.sources {
+		stats := src.Stats()
+		prev, seen := p.last[name]
+		p.last[name] = stats
+
+		p.metrics.RecordPoolStats(name, PoolMetrics{
+			MaxOpen:           stats.MaxOpenConnections,
+			Open:              stats.OpenConnections,
+			InUse:             stats.InUse,
+			Idle:              stats.Idle,
+			WaitCount:         stats.WaitCount,
+			WaitDuration:      stats.WaitDuration,
+			MaxLifetimeClosed: stats.MaxLifetimeClosed,
+			SampledAt:         now,
+		})
+
+		p.checkThresholds(name, stats, prev, seen)
+	}
+}
+
+func (p *PoolPoller) checkThresholds(name string, stats, prev sql.DBStats, seen bool) {
+	fields := logrus.Fields{
+		"pool":     name,
+		"open":     stats.OpenConnections,
+		"in_use":   stats.InUse,
+		"idle":     stats.Idle,
+		"max_open": stats.MaxOpenConnections,
+	}
+
+	if p.thresholds.MaxWaitDuration > 0 {
+		waited := stats.WaitDuration
+		waits := stats.WaitCount
+		if seen {
+			waited -= prev.WaitDuration
+			waits -= prev.WaitCount
+		}
+		if waited > p.thresholds.MaxWaitDuration {
+			p.logger.WithFields(fields).WithFields(logrus.Fields{
+				"wait_duration": waited.String(),
+				"wait_count":    waits,
+			}).Warn("Connection pool wait time above threshold")
+		}
+	}
+
+	if p.thresholds.MaxInUseRatio > 0 && stats.MaxOpenConnections > 0 {
+		ratio := float64(stats.InUse) / float64(stats.MaxOpenConnections)
+		if ratio >= p.thresholds.MaxInUseRatio {
+			p.logger.WithFields(fields).WithField("in_use_ratio", ratio).Warn("Connection pool in-use ratio above threshold")
+		}
+	}
+}
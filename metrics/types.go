@@ -1,5 +1,7 @@
 package metrics
 
+import "time"
+
 type QueryMetrics struct {
 	QueryString   string
 	ExecutionTime float64
@@ -11,3 +13,15 @@ type ConnectionMetrics struct {
 	IsActive     bool
 	LastUsed     int64
 }
+
+// PoolMetrics is a sample of a SQL connection pool's sql.DBStats.
+type PoolMetrics struct {
+	MaxOpen           int
+	Open              int
+	InUse             int
+	Idle              int
+	WaitCount         int64
+	WaitDuration      time.Duration
+	MaxLifetimeClosed int64
+	SampledAt         time.Time
+}
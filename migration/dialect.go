@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the SQL flavour of the database being migrated.
+type Dialect string
+
+const (
+	DialectUnknown  Dialect = ""
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// DetectDialect infers the dialect from the driver behind db.
+func DetectDialect(db *sql.DB) Dialect {
+	if db == nil {
+		return DialectUnknown
+	}
+
+	driver := strings.ToLower(fmt.Sprintf("%T", db.Driver()))
+	switch {
+	case strings.Contains(driver, "pq.") || strings.Contains(driver, "pgx") || strings.Contains(driver, "postgres"):
+		return DialectPostgres
+	case strings.Contains(driver, "mysql"):
+		return DialectMySQL
+	case strings.Contains(driver, "sqlite"):
+		return DialectSQLite
+	default:
+		return DialectUnknown
+	}
+}
+
+// placeholder returns the n-th (1-based) bind parameter for the dialect.
+func (d Dialect) placeholder(n int) string {
+	if d == DialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
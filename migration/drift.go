@@ -0,0 +1,144 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DriftPolicy controls what Migrate does when applied migrations no longer
+// match the loaded files.
+type DriftPolicy string
+
+const (
+	// DriftFail makes Migrate return a *DriftError without applying anything.
+	DriftFail DriftPolicy = "fail"
+	// DriftWarn logs the drift and migrates anyway.
+	DriftWarn DriftPolicy = "warn"
+)
+
+// DriftError reports applied migrations whose file changed since they ran
+// (checksum mismatch) or that are no longer loaded.
+type DriftError struct {
+	Changed []int `json:"changed,omitempty"`
+	Missing []int `json:"missing,omitempty"`
+}
+
+func (e *DriftError) Error() string {
+	var parts []string
+	if len(e.Changed) > 0 {
+		parts = append(parts, fmt.Sprintf("checksum changed for applied migrations %v", e.Changed))
+	}
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("applied migrations %v are missing", e.Missing))
+	}
+	return "migration drift detected: " + strings.Join(parts, "; ")
+}
+
+// SetDriftPolicy sets how Migrate handles drift. The default is DriftFail.
+func (m *MigrationManager) SetDriftPolicy(policy DriftPolicy) {
+	m.driftPolicy = policy
+}
+
+// Verify compares the history table with the loaded migrations and returns a
+// *DriftError if any applied migration changed or is missing.
+func (m *MigrationManager) Verify(ctx context.Context) error {
+	if err := m.ensureHistoryTable(ctx); err != nil {
+		return err
+	}
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if drift := m.detectDrift(applied); drift != nil {
+		return drift
+	}
+	return nil
+}
+
+// Repair deliberately re-baselines the history table: checksums of applied
+// migrations are replaced by those of the loaded files, and applied versions
+// that are no longer loaded are removed.
+func (m *MigrationManager) Repair(ctx context.Context) error {
+	return m.withLock(ctx, func(locked *MigrationManager) error {
+		return locked.repair(ctx)
+	})
+}
+
+func (m *MigrationManager) repair(ctx context.Context) error {
+	if err := m.ensureHistoryTable(ctx); err != nil {
+		return err
+	}
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	drift := m.detectDrift(applied)
+	if drift == nil {
+		return nil
+	}
+
+	d := m.dialect
+	for _, version := range drift.Changed {
+		migration, _ := m.migration(version)
+		_, err := m.conn.ExecContext(ctx,
+			fmt.Sprintf("UPDATE %s SET checksum = %s WHERE version = %s", HistoryTable, d.placeholder(1), d.placeholder(2)),
+			migration.Checksum, version)
+		if err != nil {
+			return fmt.Errorf("failed to repair checksum of migration %d: %w", version, err)
+		}
+		m.logger.WithFields(logrus.Fields{
+			"version":      version,
+			"old_checksum": applied[version].Checksum,
+			"new_checksum": migration.Checksum,
+		}).Warn("Migration checksum repaired")
+	}
+	for _, version := range drift.Missing {
+		if err := m.removeMigration(ctx, m.conn, version); err != nil {
+			return err
+		}
+		m.logger.WithField("version", version).Warn("Missing migration removed from history")
+	}
+	return nil
+}
+
+// checkDrift applies the drift policy before migrating.
+func (m *MigrationManager) checkDrift(applied map[int]appliedMigration) error {
+	drift := m.detectDrift(applied)
+	if drift == nil {
+		return nil
+	}
+	if m.driftPolicy == DriftWarn {
+		m.logger.WithFields(logrus.Fields{
+			"changed": drift.Changed,
+			"missing": drift.Missing,
+		}).Warn("Migration drift detected")
+		return nil
+	}
+	return drift
+}
+
+func (m *MigrationManager) detectDrift(applied map[int]appliedMigration) *DriftError {
+	drift := &DriftError{}
+	for version, a := range applied {
+		if !a.Success {
+			continue
+		}
+		migration, ok := m.migration(version)
+		switch {
+		case !ok:
+			drift.Missing = append(drift.Missing, version)
+		case migration.Checksum != a.Checksum:
+			drift.Changed = append(drift.Changed, version)
+		}
+	}
+	if len(drift.Changed) == 0 && len(drift.Missing) == 0 {
+		return nil
+	}
+	sort.Ints(drift.Changed)
+	sort.Ints(drift.Missing)
+	return drift
+}
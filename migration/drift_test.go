@@ -0,0 +1,104 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// drifted migrates 1..3 on a new database, then edits 2, drops 3 and
+// adds a pending 4.
+func drifted(t *testing.T) (*MigrationManager, fstest.MapFS) {
+	t.Helper()
+	files := fstest.MapFS{
+		"1__one.sql":   file("CREATE TABLE one (id INTEGER);"),
+		"2__two.sql":   file("CREATE TABLE two (id INTEGER);"),
+		"3__three.sql": file("CREATE TABLE three (id INTEGER);"),
+	}
+	mm := newTestManager(t, newTestDB(t), files)
+	if err := mm.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	files["2__two.sql"] = file("CREATE TABLE two (id INTEGER, name TEXT);")
+	delete(files, "3__three.sql")
+	files["4__four.sql"] = file("CREATE TABLE four (id INTEGER);")
+	return newTestManager(t, mm.db, files), files
+}
+
+func TestVerifyDrift(t *testing.T) {
+	mm, _ := drifted(t)
+
+	var drift *DriftError
+	if err := mm.Verify(context.Background()); !errors.As(err, &drift) {
+		t.Fatalf("Verify: %v, want *DriftError", err)
+	}
+	if !reflect.DeepEqual(drift.Changed, []int{2}) || !reflect.DeepEqual(drift.Missing, []int{3}) {
+		t.Errorf("drift = %+v, want changed [2] and missing [3]", drift)
+	}
+
+	statuses, err := mm.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.Modified != (s.Version == 2) {
+			t.Errorf("status of %d: modified = %v", s.Version, s.Modified)
+		}
+	}
+}
+
+func TestDriftPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	mm, _ := drifted(t)
+	var drift *DriftError
+	if err := mm.Migrate(ctx); !errors.As(err, &drift) {
+		t.Fatalf("Migrate with DriftFail: %v, want *DriftError", err)
+	}
+	if n := count(t, mm.db, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'four'"); n != 0 {
+		t.Error("Migrate with DriftFail applied a pending migration")
+	}
+
+	mm.SetDriftPolicy(DriftWarn)
+	if err := mm.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate with DriftWarn: %v", err)
+	}
+	if n := count(t, mm.db, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'four'"); n != 1 {
+		t.Error("Migrate with DriftWarn did not apply the pending migration")
+	}
+}
+
+func TestRepair(t *testing.T) {
+	ctx := context.Background()
+	mm, files := drifted(t)
+
+	if err := mm.Repair(ctx); err != nil {
+		t.Fatalf("Repair: %v", err)
+	}
+	if err := mm.Verify(ctx); err != nil {
+		t.Errorf("Verify after Repair: %v", err)
+	}
+
+	applied, err := mm.appliedMigrations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := applied[3]; ok {
+		t.Error("missing migration 3 still in the history table")
+	}
+	if want := checksum(files["2__two.sql"].Data); applied[2].Checksum != want {
+		t.Errorf("checksum of 2 = %s, want %s", applied[2].Checksum, want)
+	}
+	// Repair re-baselines only; the edited file is not re-run.
+	if n := count(t, mm.db, "SELECT COUNT(*) FROM pragma_table_info('two') WHERE name = 'name'"); n != 0 {
+		t.Error("Repair ran the edited migration")
+	}
+
+	// Nothing to repair is a no-op.
+	if err := mm.Repair(ctx); err != nil {
+		t.Errorf("second Repair: %v", err)
+	}
+}
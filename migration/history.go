@@ -0,0 +1,206 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// HistoryTable records which migrations have been applied.
+const HistoryTable = "schema_migrations"
+
+// MigrationState is the state of a migration as reported by Status.
+type MigrationState string
+
+const (
+	// StateApplied means the migration ran successfully.
+	StateApplied MigrationState = "applied"
+	// StateFailed means the last attempt failed; Migrate retries it.
+	StateFailed MigrationState = "failed"
+	// StatePending means the migration is loaded but has not run.
+	StatePending MigrationState = "pending"
+	// StateMissing means the migration was applied but is no longer loaded.
+	StateMissing MigrationState = "missing"
+)
+
+// MigrationStatus describes one migration version.
+type MigrationStatus struct {
+	Version     int
+	Description string
+	State       MigrationState
+	Checksum    string
+	// Modified is set when the loaded file's checksum differs from the one
+	// recorded when the migration was applied.
+	Modified      bool
+	AppliedAt     time.Time
+	ExecutionTime time.Duration
+}
+
+// appliedMigration is a row of the history table.
+type appliedMigration struct {
+	Version       int
+	Description   string
+	Checksum      string
+	AppliedAt     time.Time
+	ExecutionTime time.Duration
+	Success       bool
+}
+
+// ensureHistoryTable creates the history table if it does not exist. The
+// column types are understood by Postgres, MySQL and SQLite alike.
+func (m *MigrationManager) ensureHistoryTable(ctx context.Context) error {
+	_, err := m.conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+HistoryTable+` (
+	version BIGINT PRIMARY KEY,
+	description VARCHAR(255) NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	applied_at TIMESTAMP NOT NULL,
+	execution_time BIGINT NOT NULL,
+	success BOOLEAN NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create %s table: %w", HistoryTable, err)
+	}
+	return nil
+}
+
+// appliedMigrations reads the history table keyed by version.
+func (m *MigrationManager) appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	rows, err := m.conn.QueryContext(ctx,
+		"SELECT version, description, checksum, applied_at, execution_time, success FROM "+HistoryTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", HistoryTable, err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		var appliedAt timestamp
+		var executionMs int64
+		if err := rows.Scan(&a.Version, &a.Description, &a.Checksum, &appliedAt, &executionMs, &a.Success); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", HistoryTable, err)
+		}
+		a.AppliedAt = appliedAt.Time
+		a.ExecutionTime = time.Duration(executionMs) * time.Millisecond
+		applied[a.Version] = a
+	}
+	return applied, rows.Err()
+}
+
+// recordMigration stores the outcome of running migration, replacing any
+// earlier (failed) attempt.
+func (m *MigrationManager) recordMigration(ctx context.Context, q execer, migration Migration, duration time.Duration, success bool) error {
+	d := m.dialect
+	if _, err := q.ExecContext(ctx, "DELETE FROM "+HistoryTable+" WHERE version = "+d.placeholder(1), migration.Version); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+
+	_, err := q.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (version, description, checksum, applied_at, execution_time, success) VALUES (%s, %s, %s, %s, %s, %s)",
+		HistoryTable, d.placeholder(1), d.placeholder(2), d.placeholder(3), d.placeholder(4), d.placeholder(5), d.placeholder(6)),
+		migration.Version, migration.Description, migration.Checksum, time.Now().UTC(), duration.Milliseconds(), success)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+	return nil
+}
+
+// removeMigration deletes version from the history table after a rollback.
+func (m *MigrationManager) removeMigration(ctx context.Context, q execer, version int) error {
+	if _, err := q.ExecContext(ctx, "DELETE FROM "+HistoryTable+" WHERE version = "+m.dialect.placeholder(1), version); err != nil {
+		return fmt.Errorf("failed to remove migration %d from %s: %w", version, HistoryTable, err)
+	}
+	return nil
+}
+
+// Status reports every loaded or previously applied migration in version order.
+func (m *MigrationManager) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureHistoryTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	loaded := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		loaded[migration.Version] = true
+		status := MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+			State:       StatePending,
+			Checksum:    migration.Checksum,
+		}
+		if a, ok := applied[migration.Version]; ok {
+			status.State = StateFailed
+			if a.Success {
+				status.State = StateApplied
+			}
+			status.AppliedAt = a.AppliedAt
+			status.ExecutionTime = a.ExecutionTime
+			status.Modified = a.Success && a.Checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	for version, a := range applied {
+		if loaded[version] || !a.Success {
+			continue
+		}
+		statuses = append(statuses, MigrationStatus{
+			Version:       a.Version,
+			Description:   a.Description,
+			State:         StateMissing,
+			Checksum:      a.Checksum,
+			AppliedAt:     a.AppliedAt,
+			ExecutionTime: a.ExecutionTime,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// timestamp scans applied_at whether the driver returns a time.Time or, as
+// MySQL does without parseTime=true, text.
+type timestamp struct {
+	time.Time
+}
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+func (t *timestamp) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("cannot scan %T into timestamp", src)
+	}
+
+	for _, layout := range timestampLayouts {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse timestamp %q", text)
+}
@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// loadMigrations reads the .sql files in dir within fsys, sorted by version.
+// It backs both MigrationManager and Migrator. A version is either one file,
+// optionally split by "-- +migrate Down" into up and down sections, or a pair
+// of <version>__<description>.up.sql and .down.sql files.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration directory: %w", err)
+	}
+
+	found := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		version, description, direction, err := parseMigrationFile(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := found[version]
+		if !ok {
+			migration = &Migration{Version: version, Description: description}
+			found[version] = migration
+		}
+		if err := migration.addScript(entry.Name(), direction, string(script)); err != nil {
+			return nil, err
+		}
+	}
+
+	migrations := make([]Migration, 0, len(found))
+	for version, migration := range found {
+		if migration.Script == "" {
+			return nil, fmt.Errorf("migration %d has no up script", version)
+		}
+		migration.Checksum = checksum([]byte(migration.Script))
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// addScript sets the up and/or down script of m from one migration file.
+func (m *Migration) addScript(filename, direction, script string) error {
+	var up, down string
+	upLine, downLine := 1, 1
+	switch direction {
+	case directionUp:
+		up = script
+	case directionDown:
+		down = script
+	default:
+		up, upLine, down, downLine = splitSections(script)
+	}
+
+	if (up != "" && m.Script != "") || (down != "" && m.Down != "") {
+		return fmt.Errorf("duplicate migration version %d: %s", m.Version, filename)
+	}
+	if up != "" {
+		m.Script, m.scriptLine = up, upLine
+	}
+	if hasDirective(script, "notransaction") {
+		m.NoTransaction = true
+	}
+	if down != "" {
+		m.Down, m.downLine = down, downLine
+	}
+	return nil
+}
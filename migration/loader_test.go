@@ -0,0 +1,138 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitSections(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		up       string
+		upLine   int
+		down     string
+		downLine int
+	}{
+		{
+			name:   "up only",
+			script: "CREATE TABLE a (id INT);\n",
+			up:     "CREATE TABLE a (id INT);", upLine: 1,
+		},
+		{
+			name:   "down section",
+			script: "CREATE TABLE a (id INT);\n\n-- +migrate Down\nDROP TABLE a;\n",
+			up:     "CREATE TABLE a (id INT);", upLine: 1,
+			down: "DROP TABLE a;", downLine: 4,
+		},
+		{
+			name:   "explicit up with blank lines",
+			script: "-- +migrate Up\n\n\nCREATE TABLE a (id INT);\n-- +migrate Down\n\n  DROP TABLE a;",
+			up:     "CREATE TABLE a (id INT);", upLine: 4,
+			down: "DROP TABLE a;", downLine: 7,
+		},
+		{
+			name:   "directive case and spacing",
+			script: "--   +MIGRATE   up\nSELECT 1;\n--\t+migrate\tDOWN\nSELECT 2;",
+			up:     "SELECT 1;", upLine: 2,
+			down: "SELECT 2;", downLine: 4,
+		},
+		{
+			name:   "leading comment kept",
+			script: "\n-- creates a\nCREATE TABLE a (id INT);",
+			up:     "-- creates a\nCREATE TABLE a (id INT);", upLine: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, upLine, down, downLine := splitSections(tt.script)
+			if up != tt.up || upLine != tt.upLine {
+				t.Errorf("up = %q at line %d, want %q at line %d", up, upLine, tt.up, tt.upLine)
+			}
+			if down != tt.down || (tt.down != "" && downLine != tt.downLine) {
+				t.Errorf("down = %q at line %d, want %q at line %d", down, downLine, tt.down, tt.downLine)
+			}
+		})
+	}
+}
+
+func TestTrimScript(t *testing.T) {
+	tests := []struct {
+		script string
+		line   int
+		want   string
+		wantAt int
+	}{
+		{"SELECT 1;", 1, "SELECT 1;", 1},
+		{"\n\nSELECT 1;\n\n", 1, "SELECT 1;", 3},
+		{"  \n\t\n  SELECT 1;", 5, "SELECT 1;", 7},
+		{"\r\n\r\nSELECT 1;", 2, "SELECT 1;", 4},
+		{"\n\n", 3, "", 5},
+	}
+
+	for _, tt := range tests {
+		got, at := trimScript(tt.script, tt.line)
+		if got != tt.want || at != tt.wantAt {
+			t.Errorf("trimScript(%q, %d) = %q, %d; want %q, %d", tt.script, tt.line, got, at, tt.want, tt.wantAt)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"sql/2__pair.up.sql":   file("\n\nCREATE TABLE b (id INT);"),
+		"sql/2__pair.down.sql": file("DROP TABLE b;"),
+		"sql/1__combined.sql":  file("CREATE TABLE a (id INT);\n-- +migrate Down\nDROP TABLE a;"),
+		"sql/3__no_tx.sql":     file("-- +migrate NoTransaction\nVACUUM;"),
+		"sql/README.md":        file("not a migration"),
+		"sql/nested/9__x.sql":  file("SELECT 1;"),
+	}
+
+	migrations, err := loadMigrations(files, "sql")
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("loaded %d migrations, want 3", len(migrations))
+	}
+
+	combined, pair, noTx := migrations[0], migrations[1], migrations[2]
+	if combined.Version != 1 || combined.Description != "combined" || combined.Down != "DROP TABLE a;" {
+		t.Errorf("combined = %+v", combined)
+	}
+	// Paired files are used as they are; statement lines count from line 1.
+	if pair.Version != 2 || pair.Description != "pair" || pair.Script != "\n\nCREATE TABLE b (id INT);" ||
+		pair.scriptLine != 1 || pair.Down != "DROP TABLE b;" {
+		t.Errorf("pair = %+v", pair)
+	}
+	if !noTx.NoTransaction || noTx.Script != "-- +migrate NoTransaction\nVACUUM;" || noTx.reversible() {
+		t.Errorf("no transaction = %+v", noTx)
+	}
+	for _, m := range migrations {
+		if m.Checksum != checksum([]byte(m.Script)) {
+			t.Errorf("migration %d checksum does not match its up script", m.Version)
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"down without up", fstest.MapFS{"1__a.down.sql": file("DROP TABLE a;")}},
+		{"duplicate up", fstest.MapFS{"1__a.sql": file("SELECT 1;"), "1__a.up.sql": file("SELECT 2;")}},
+		{"duplicate down", fstest.MapFS{"1__a.sql": file("SELECT 1;\n-- +migrate Down\nSELECT 2;"), "1__a.down.sql": file("SELECT 3;")}},
+		{"bad name", fstest.MapFS{"add_users.sql": file("SELECT 1;")}},
+		{"bad version", fstest.MapFS{"v1__add_users.sql": file("SELECT 1;")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadMigrations(tt.files, "."); err == nil {
+				t.Error("loadMigrations succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// DefaultLockTimeout is how long a MigrationManager waits for the migration
+// lock unless SetLockTimeout is called.
+const DefaultLockTimeout = 5 * time.Minute
+
+// lockPollInterval is how often a busy lock is retried.
+const lockPollInterval = 500 * time.Millisecond
+
+// ErrLockTimeout is returned when the migration lock could not be acquired
+// within the lock timeout.
+var ErrLockTimeout = errors.New("timed out waiting for migration lock")
+
+// errRelock is returned when SQLite's lock could not be taken again after a
+// NoTransaction migration that succeeded.
+var errRelock = errors.New("failed to retake migration lock")
+
+// lockName identifies the migration lock; Postgres uses its 64-bit hash.
+const lockName = HistoryTable
+
+// SetLockTimeout sets how long Migrate, Rollback, RollbackTo, Redo, Repair and
+// ApplyMigration wait for another instance to release the migration lock.
+// A timeout <= 0 waits until the context is done.
+func (m *MigrationManager) SetLockTimeout(timeout time.Duration) {
+	m.lockTimeout = timeout
+}
+
+// withLock runs fn while holding a database-wide migration lock, so that when
+// several instances start together exactly one migrates and the others wait,
+// then find nothing pending. fn receives a copy of m that runs every statement
+// on the connection holding the lock:
+//   - Postgres: a session advisory lock (pg_try_advisory_lock, polled).
+//   - MySQL: GET_LOCK with the remaining timeout.
+//   - SQLite: a write transaction, which only one connection can hold,
+//     committed when fn returns; migrations then run in savepoints inside it,
+//     except NoTransaction ones, which commit it and take it again after.
+//
+// Other dialects run fn without a lock.
+func (m *MigrationManager) withLock(ctx context.Context, fn func(locked *MigrationManager) error) (err error) {
+	if m.dialect == DialectUnknown {
+		return fn(m)
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	lockCtx := ctx
+	if m.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, m.lockTimeout)
+		defer cancel()
+	}
+
+	locked := *m
+	unlock, err := locked.lock(ctx, lockCtx, conn)
+	if err != nil {
+		return err
+	}
+	finished := false
+	defer func() {
+		// Unlock even if the caller's context was canceled or fn panicked; a
+		// panic rolls SQLite's lock transaction back instead of committing it.
+		if unlockErr := unlock(context.Background(), !finished); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	err = fn(&locked)
+	finished = true
+	return err
+}
+
+// lock acquires the dialect's migration lock on conn, waiting until lockCtx is
+// done, points m at the locked connection and returns the release func. With
+// abort set, the release discards the work done under the lock where it can.
+func (m *MigrationManager) lock(ctx, lockCtx context.Context, conn *sql.Conn) (func(ctx context.Context, abort bool) error, error) {
+	switch m.dialect {
+	case DialectPostgres:
+		h := fnv.New64a()
+		h.Write([]byte(lockName))
+		key := int64(h.Sum64())
+
+		err := pollLock(lockCtx, func() (bool, error) {
+			var acquired bool
+			err := conn.QueryRowContext(lockCtx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired)
+			return acquired, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		m.conn, m.session = conn, conn
+		return func(ctx context.Context, _ bool) error {
+			if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key); err != nil {
+				// Closing the session is the only other way to release the lock.
+				discardConn(conn)
+				return fmt.Errorf("failed to release migration lock: %w", err)
+			}
+			return nil
+		}, nil
+
+	case DialectMySQL:
+		// GET_LOCK waits server-side; -1 waits forever.
+		wait := -1
+		if deadline, ok := lockCtx.Deadline(); ok {
+			wait = int(time.Until(deadline).Seconds())
+			if wait < 0 {
+				wait = 0
+			}
+		}
+		var acquired sql.NullInt64
+		if err := conn.QueryRowContext(lockCtx, "SELECT GET_LOCK(?, ?)", lockName, wait).Scan(&acquired); err != nil {
+			return nil, lockError(lockCtx, err)
+		}
+		if acquired.Int64 != 1 {
+			return nil, ErrLockTimeout
+		}
+		m.conn, m.session = conn, conn
+		return func(ctx context.Context, _ bool) error {
+			if _, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName); err != nil {
+				discardConn(conn)
+				return fmt.Errorf("failed to release migration lock: %w", err)
+			}
+			return nil
+		}, nil
+
+	case DialectSQLite:
+		m.session = conn
+		if err := m.lockSQLite(ctx, lockCtx); err != nil {
+			return nil, err
+		}
+		return func(_ context.Context, abort bool) error {
+			// lockTx is nil if retaking the lock after a NoTransaction
+			// migration failed.
+			tx := m.lockTx
+			if tx == nil {
+				return nil
+			}
+			if abort {
+				tx.Rollback()
+				return nil
+			}
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("failed to release migration lock: %w", err)
+			}
+			return nil
+		}, nil
+	}
+	return func(context.Context, bool) error { return nil }, nil
+}
+
+// lockSQLite opens the write transaction that serves as SQLite's migration
+// lock on m.session, waiting until lockCtx is done.
+func (m *MigrationManager) lockSQLite(ctx, lockCtx context.Context) error {
+	return pollLock(lockCtx, func() (bool, error) {
+		// The transaction lives for the whole run, so it uses ctx, not lockCtx.
+		tx, err := m.session.BeginTx(ctx, nil)
+		if err != nil {
+			return false, err
+		}
+		m.conn, m.lockTx = tx, tx
+		// The first write takes SQLite's write lock; a second migrator gets SQLITE_BUSY.
+		err = m.ensureHistoryTable(ctx)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "DELETE FROM "+HistoryTable+" WHERE 1 = 0")
+		}
+		if err != nil {
+			tx.Rollback()
+			m.conn, m.lockTx = m.session, nil
+			if isSQLiteBusy(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+// outsideLockTx runs fn on SQLite's locked connection with the lock
+// transaction committed, for NoTransaction migrations such as VACUUM, and then
+// takes the lock again. Another instance may take the lock in between, which
+// at worst makes it run the same pending migrations, so such migrations should
+// be safe to repeat.
+func (m *MigrationManager) outsideLockTx(ctx context.Context, fn func(q execer) error) error {
+	if err := m.lockTx.Commit(); err != nil {
+		return fmt.Errorf("failed to release migration lock: %w", err)
+	}
+	m.conn, m.lockTx = m.session, nil
+
+	err := fn(m.session)
+
+	lockCtx := ctx
+	if m.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, m.lockTimeout)
+		defer cancel()
+	}
+	if lockErr := m.lockSQLite(ctx, lockCtx); lockErr != nil && err == nil {
+		// fn's work is committed, so this must not be recorded as its failure.
+		return fmt.Errorf("%w: %w", errRelock, lockErr)
+	}
+	return err
+}
+
+// pollLock calls try until it acquires the lock, fails, or ctx is done.
+func pollLock(ctx context.Context, try func() (bool, error)) error {
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		acquired, err := try()
+		if err != nil {
+			return lockError(ctx, err)
+		}
+		if acquired {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return lockError(ctx, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// lockError reports a lock wait that ran out of time as ErrLockTimeout.
+func lockError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrLockTimeout
+	}
+	return fmt.Errorf("failed to acquire migration lock: %w", err)
+}
+
+// discardConn closes conn without returning it to the pool, ending its
+// session and with it any lock the session still holds.
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	conn.Close()
+}
+
+func isSQLiteBusy(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "busy")
+}
@@ -1,13 +1,23 @@
 package migration
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
+	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yoockh/dbyoc/logger"
 )
 
 // Migration represents a single database migration.
@@ -15,47 +25,97 @@ type Migration struct {
 	Version     int
 	Description string
 	Script      string
+	// Down reverts Script; empty if the migration cannot be rolled back.
+	Down string
+	// Checksum is the hex SHA-256 of Script, stored in the history table.
+	Checksum string
+	// NoTransaction runs the migration outside a transaction, for statements
+	// such as CREATE INDEX CONCURRENTLY. Set by a "-- +migrate NoTransaction" line.
+	NoTransaction bool
+	// UpFunc and DownFunc are set instead of Script and Down for Go
+	// migrations added with Register.
+	UpFunc   MigrationFunc
+	DownFunc MigrationFunc
+
+	// scriptLine and downLine are the file lines Script and Down start on, so
+	// statement errors point into the file.
+	scriptLine int
+	downLine   int
+}
+
+// execer is satisfied by *sql.DB, *sql.Conn and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// queryer is satisfied by *sql.DB, *sql.Conn and *sql.Tx.
+type queryer interface {
+	execer
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 }
 
 // MigrationManager manages the migrations.
 type MigrationManager struct {
-	db         *sql.DB
+	db *sql.DB
+	// conn is db, or the connection or transaction holding the migration lock.
+	conn queryer
+	// session is the connection holding the migration lock.
+	session *sql.Conn
+	// lockTx is SQLite's lock transaction; migrations run in savepoints in it.
+	lockTx     *sql.Tx
+	dialect    Dialect
 	migrations []Migration
+	logger     *logrus.Logger
+
+	driftPolicy DriftPolicy
+	lockTimeout time.Duration
 }
 
-// NewMigrationManager creates a new MigrationManager.
+// NewMigrationManager creates a new MigrationManager. The dialect (Postgres,
+// MySQL or SQLite) is detected from the driver behind db. Go migrations added
+// with Register are loaded immediately.
 func NewMigrationManager(db *sql.DB) *MigrationManager {
 	return &MigrationManager{
-		db: db,
+		db:         db,
+		conn:       db,
+		dialect:    DetectDialect(db),
+		migrations: registeredMigrations(),
+		logger:     logger.GetLogger(),
+
+		driftPolicy: DriftFail,
+		lockTimeout: DefaultLockTimeout,
 	}
 }
 
+// Dialect returns the detected dialect of the managed database.
+func (m *MigrationManager) Dialect() Dialect {
+	return m.dialect
+}
+
 // LoadMigrations loads migration scripts from the specified directory.
 func (m *MigrationManager) LoadMigrations(dir string) error {
-	files, err := ioutil.ReadDir(dir)
+	return m.LoadMigrationsFS(os.DirFS(dir), ".")
+}
+
+// LoadMigrationsFS loads migration scripts from dir within fsys, e.g. an
+// embed.FS:
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	mm.LoadMigrationsFS(migrations, "migrations")
+func (m *MigrationManager) LoadMigrationsFS(fsys fs.FS, dir string) error {
+	migrations, err := loadMigrations(fsys, dir)
 	if err != nil {
-		return fmt.Errorf("failed to read migration directory: %w", err)
-	}
-
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), ".sql") {
-			version, description, err := parseMigrationFile(file.Name())
-			if err != nil {
-				return err
-			}
-
-			script, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
-			if err != nil {
-				return fmt.Errorf("failed to read migration file %s: %w", file.Name(), err)
-			}
-
-			m.migrations = append(m.migrations, Migration{
-				Version:     version,
-				Description: description,
-				Script:      string(script),
-			})
+		return err
+	}
+
+	for _, migration := range migrations {
+		if _, ok := m.migration(migration.Version); ok {
+			return fmt.Errorf("duplicate migration version %d", migration.Version)
 		}
 	}
+	m.migrations = append(m.migrations, migrations...)
 
 	sort.Slice(m.migrations, func(i, j int) bool {
 		return m.migrations[i].Version < m.migrations[j].Version
@@ -64,31 +124,258 @@ func (m *MigrationManager) LoadMigrations(dir string) error {
 	return nil
 }
 
-// ApplyMigration applies a migration to the database.
+// Migrate applies, in version order, every loaded migration that is not
+// recorded as applied in the history table. Failed migrations are retried.
+// Applied migrations are first checked for drift (see SetDriftPolicy), and the
+// whole run holds the migration lock (see SetLockTimeout).
+func (m *MigrationManager) Migrate(ctx context.Context) error {
+	return m.withLock(ctx, func(locked *MigrationManager) error {
+		return locked.migrate(ctx)
+	})
+}
+
+func (m *MigrationManager) migrate(ctx context.Context) error {
+	if err := m.ensureHistoryTable(ctx); err != nil {
+		return err
+	}
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.checkDrift(applied); err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if a, ok := applied[migration.Version]; ok && a.Success {
+			continue
+		}
+		if err := m.apply(ctx, migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyMigration applies a migration to the database and records it in the
+// history table, whether or not it was applied before.
 func (m *MigrationManager) ApplyMigration(migration Migration) error {
-	_, err := m.db.Exec(migration.Script)
+	ctx := context.Background()
+	return m.withLock(ctx, func(locked *MigrationManager) error {
+		if err := locked.ensureHistoryTable(ctx); err != nil {
+			return err
+		}
+		return locked.apply(ctx, migration)
+	})
+}
+
+func (m *MigrationManager) apply(ctx context.Context, migration Migration) error {
+	if migration.Checksum == "" {
+		migration.Checksum = checksum([]byte(migration.Script))
+	}
+
+	start := time.Now()
+	err := m.inTx(ctx, migration.NoTransaction, func(q execer) error {
+		if err := migration.runUp(ctx, q, m.dialect); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", migration.Version, err)
+		}
+		return m.recordMigration(ctx, q, migration, time.Since(start), true)
+	})
+	duration := time.Since(start)
+
+	if errors.Is(err, errRelock) {
+		return fmt.Errorf("migration %d was applied: %w", migration.Version, err)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to apply migration %d: %w", migration.Version, err)
+		// The transaction was rolled back, so record the failure outside it.
+		if recErr := m.recordMigration(ctx, m.conn, migration, duration, false); recErr != nil {
+			m.logger.WithError(recErr).Warn("Failed to record failed migration")
+		}
+		return err
 	}
+
+	m.logger.WithFields(logrus.Fields{
+		"version":     migration.Version,
+		"description": migration.Description,
+		"duration_ms": duration.Milliseconds(),
+	}).Info("Migration applied")
 	return nil
 }
 
-// parseMigrationFile extracts version and description from the migration file name.
-func parseMigrationFile(filename string) (int, string, error) {
+// runUp executes the migration's Go function, or its SQL script one
+// statement at a time, on q.
+func (m Migration) runUp(ctx context.Context, q execer, dialect Dialect) error {
+	if m.UpFunc != nil {
+		return runFunc(ctx, q, m.UpFunc)
+	}
+	return runScript(ctx, q, dialect, m.Script, m.scriptLine)
+}
+
+// runDown executes the migration's down Go function or script on q.
+func (m Migration) runDown(ctx context.Context, q execer, dialect Dialect) error {
+	if m.DownFunc != nil {
+		return runFunc(ctx, q, m.DownFunc)
+	}
+	return runScript(ctx, q, dialect, m.Down, m.downLine)
+}
+
+// reversible reports whether the migration has a down script or function.
+func (m Migration) reversible() bool {
+	return m.Down != "" || m.DownFunc != nil
+}
+
+// inTx runs fn in a transaction committed only if fn succeeds, or directly on
+// the database when noTx is set (on SQLite, outside the lock transaction). MySQL commits DDL statements implicitly, so
+// there only data changes and the history row are rolled back on failure.
+func (m *MigrationManager) inTx(ctx context.Context, noTx bool, fn func(q execer) error) error {
+	if noTx {
+		if m.lockTx != nil {
+			return m.outsideLockTx(ctx, fn)
+		}
+		return fn(m.conn)
+	}
+	if m.lockTx != nil {
+		return inSavepoint(ctx, m.lockTx, fn)
+	}
+
+	var tx *sql.Tx
+	var err error
+	if m.session != nil {
+		tx, err = m.session.BeginTx(ctx, nil)
+	} else {
+		tx, err = m.db.BeginTx(ctx, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	// A no-op after Commit; it also ends the transaction if fn panics, which
+	// would otherwise keep the locked connection from being closed.
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration transaction: %w", err)
+	}
+	return nil
+}
+
+// inSavepoint is inTx for use inside an already open transaction.
+func inSavepoint(ctx context.Context, tx *sql.Tx, fn func(q execer) error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT migration"); err != nil {
+		return fmt.Errorf("failed to create migration savepoint: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT migration")
+		tx.ExecContext(ctx, "RELEASE SAVEPOINT migration")
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT migration"); err != nil {
+		return fmt.Errorf("failed to release migration savepoint: %w", err)
+	}
+	return nil
+}
+
+const (
+	directionUp   = "up"
+	directionDown = "down"
+)
+
+// ParseFileName parses a migration file name of the form
+// <version>__<description>[.up|.down].<ext> into its version, description and
+// direction ("up", "down" or "" for a combined file). Runners for other stores,
+// such as migration/mongo, use it to share the SQL naming convention.
+func ParseFileName(filename string) (version int, description, direction string, err error) {
+	return parseMigrationFile(filename)
+}
+
+// parseMigrationFile extracts version and description from the migration file
+// name, along with the direction ("up", "down" or "" for a combined file) of
+// .up.sql and .down.sql files.
+func parseMigrationFile(filename string) (int, string, string, error) {
 	parts := strings.SplitN(filename, "__", 2)
 	if len(parts) != 2 {
-		return 0, "", fmt.Errorf("invalid migration file name format: %s", filename)
+		return 0, "", "", fmt.Errorf("invalid migration file name format: %s", filename)
 	}
 
 	version := parts[0]
 	description := strings.TrimSuffix(parts[1], filepath.Ext(parts[1]))
 
+	direction := ""
+	for _, d := range []string{directionUp, directionDown} {
+		if strings.HasSuffix(description, "."+d) {
+			description = strings.TrimSuffix(description, "."+d)
+			direction = d
+		}
+	}
+
 	versionInt, err := strconv.Atoi(version)
 	if err != nil {
-		return 0, "", fmt.Errorf("invalid migration version: %s", version)
+		return 0, "", "", fmt.Errorf("invalid migration version: %s", version)
+	}
+
+	return versionInt, description, direction, nil
+}
+
+// splitSections splits a combined migration file at its "-- +migrate Down"
+// line. A leading "-- +migrate Up" line is optional. Besides each section it
+// returns the file line its text starts on.
+func splitSections(script string) (string, int, string, int) {
+	var up, down strings.Builder
+	upLine, downLine := 1, 0
+	target, targetLine := &up, &upLine
+	for i, line := range strings.SplitAfter(script, "\n") {
+		switch directive(line) {
+		case "up":
+			target, targetLine = &up, &upLine
+			continue
+		case "down":
+			target, targetLine = &down, &downLine
+			continue
+		}
+		if target.Len() == 0 {
+			*targetLine = i + 1
+		}
+		target.WriteString(line)
 	}
 
-	return versionInt, description, nil
+	upScript, upLine := trimScript(up.String(), upLine)
+	downScript, downLine := trimScript(down.String(), downLine)
+	return upScript, upLine, downScript, downLine
+}
+
+// trimScript trims surrounding whitespace from script, which starts on line,
+// and returns the line the trimmed text starts on.
+func trimScript(script string, line int) (string, int) {
+	trimmed := strings.TrimLeftFunc(script, unicode.IsSpace)
+	line += strings.Count(script[:len(script)-len(trimmed)], "\n")
+	return strings.TrimSpace(trimmed), line
+}
+
+// directive returns the lower-cased name of a "-- +migrate <name>" line, or "".
+func directive(line string) string {
+	fields := strings.Fields(strings.ToLower(line))
+	if len(fields) == 3 && fields[0] == "--" && fields[1] == "+migrate" {
+		return fields[2]
+	}
+	return ""
+}
+
+// hasDirective reports whether script contains a "-- +migrate <name>" line.
+func hasDirective(script, name string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		if directive(line) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checksum returns the hex SHA-256 of a migration script.
+func checksum(script []byte) string {
+	sum := sha256.Sum256(script)
+	return hex.EncodeToString(sum[:])
 }
 
 // GetMigrations returns the list of loaded migrations.
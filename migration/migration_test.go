@@ -0,0 +1,203 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+// newTestDB opens an in-memory SQLite database. Every connection to
+// :memory: is a separate database, so the pool is limited to one.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestManager returns a MigrationManager for db with files loaded.
+func newTestManager(t *testing.T, db *sql.DB, files fstest.MapFS) *MigrationManager {
+	t.Helper()
+	mm := NewMigrationManager(db)
+	if err := mm.LoadMigrationsFS(files, "."); err != nil {
+		t.Fatalf("LoadMigrationsFS: %v", err)
+	}
+	return mm
+}
+
+func file(script string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(script)}
+}
+
+// states returns the state of every version reported by Status.
+func states(t *testing.T, mm *MigrationManager) map[int]MigrationState {
+	t.Helper()
+	statuses, err := mm.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	got := make(map[int]MigrationState, len(statuses))
+	for _, s := range statuses {
+		got[s.Version] = s.State
+	}
+	return got
+}
+
+func count(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestMigrateAppliesOnce(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	files := fstest.MapFS{
+		"1__create_users.sql": file("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);"),
+		"2__seed_users.sql":   file("INSERT INTO users (name) VALUES ('ada');"),
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := newTestManager(t, db, files).Migrate(ctx); err != nil {
+			t.Fatalf("Migrate #%d: %v", i+1, err)
+		}
+	}
+	if n := count(t, db, "SELECT COUNT(*) FROM users"); n != 1 {
+		t.Errorf("users = %d, want 1: applied migration ran again", n)
+	}
+	if n := count(t, db, "SELECT COUNT(*) FROM "+HistoryTable+" WHERE success"); n != 2 {
+		t.Errorf("successful history rows = %d, want 2", n)
+	}
+
+	statuses, err := newTestManager(t, db, files).Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.State != StateApplied || s.AppliedAt.IsZero() || s.Modified {
+			t.Errorf("status of %d = %+v, want applied and unmodified", s.Version, s)
+		}
+	}
+}
+
+func TestMigrateRetriesFailed(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	files := fstest.MapFS{
+		"1__create_users.sql": file("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);"),
+		"2__add_email.sql":    file("ALTER TABLE users ADD COLUMN email TEXT;\nINSERT INTO missing VALUES (1);"),
+		"3__seed_users.sql":   file("INSERT INTO users (name) VALUES ('ada');"),
+	}
+
+	if err := newTestManager(t, db, files).Migrate(ctx); err == nil {
+		t.Fatal("Migrate with a broken migration succeeded")
+	}
+	want := map[int]MigrationState{1: StateApplied, 2: StateFailed, 3: StatePending}
+	if got := states(t, newTestManager(t, db, files)); !maps.Equal(got, want) {
+		t.Errorf("states after failure = %v, want %v", got, want)
+	}
+	// The failed migration's transaction was rolled back.
+	if n := count(t, db, "SELECT COUNT(*) FROM pragma_table_info('users') WHERE name = 'email'"); n != 0 {
+		t.Error("failed migration left its column behind")
+	}
+
+	files["2__add_email.sql"] = file("ALTER TABLE users ADD COLUMN email TEXT;")
+	if err := newTestManager(t, db, files).Migrate(ctx); err != nil {
+		t.Fatalf("Migrate after fixing the migration: %v", err)
+	}
+	want = map[int]MigrationState{1: StateApplied, 2: StateApplied, 3: StateApplied}
+	if got := states(t, newTestManager(t, db, files)); !maps.Equal(got, want) {
+		t.Errorf("states after retry = %v, want %v", got, want)
+	}
+}
+
+func TestStatusMissing(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	files := fstest.MapFS{
+		"1__one.sql": file("CREATE TABLE one (id INTEGER);"),
+		"2__two.sql": file("CREATE TABLE two (id INTEGER);"),
+	}
+	if err := newTestManager(t, db, files).Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	delete(files, "1__one.sql")
+	files["3__three.sql"] = file("CREATE TABLE three (id INTEGER);")
+	want := map[int]MigrationState{1: StateMissing, 2: StateApplied, 3: StatePending}
+	if got := states(t, newTestManager(t, db, files)); !maps.Equal(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db := newTestDB(t)
+	files := fstest.MapFS{
+		"migrations/1__create_users.sql": file("CREATE TABLE users (id INTEGER PRIMARY KEY);\n-- +migrate Down\nDROP TABLE users;"),
+	}
+	m := NewMigratorFS(db, files)
+
+	if err := m.Migrate("migrations"); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	statuses, err := m.Status("migrations")
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].State != StateApplied {
+		t.Errorf("Status = %+v, want one applied migration", statuses)
+	}
+	if err := m.Rollback("migrations", 1); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if n := count(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'users'"); n != 0 {
+		t.Error("users table still exists after Rollback")
+	}
+}
+
+func TestNoTransactionSQLite(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	files := fstest.MapFS{
+		"1__create_users.sql": file("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+		"2__vacuum.sql":       file("-- +migrate NoTransaction\nVACUUM;\n-- +migrate Down\nVACUUM;"),
+		"3__seed_users.sql":   file("INSERT INTO users VALUES (1);\n-- +migrate Down\nDELETE FROM users;"),
+	}
+	mm := newTestManager(t, db, files)
+
+	if err := mm.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	want := map[int]MigrationState{1: StateApplied, 2: StateApplied, 3: StateApplied}
+	if got := states(t, mm); !maps.Equal(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+	if err := mm.Rollback(ctx, 2); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if n := count(t, db, "SELECT COUNT(*) FROM users"); n != 0 {
+		t.Errorf("users = %d after Rollback, want 0", n)
+	}
+
+	// A failing NoTransaction migration is recorded and stops the run.
+	files["2__vacuum.sql"] = file("-- +migrate NoTransaction\nVACUUM nope;")
+	mm = newTestManager(t, db, files)
+	if err := mm.Migrate(ctx); err == nil {
+		t.Fatal("Migrate with a failing NoTransaction migration succeeded")
+	}
+	want = map[int]MigrationState{1: StateApplied, 2: StateFailed, 3: StatePending}
+	if got := states(t, mm); !maps.Equal(got, want) {
+		t.Errorf("states after failure = %v, want %v", got, want)
+	}
+}
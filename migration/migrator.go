@@ -1,54 +1,123 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"io/ioutil"
-	"path/filepath"
-	"strings"
+	"io"
+	"io/fs"
+	"os"
+	"time"
 )
 
+// Migrator runs the migrations in a directory against db, recording them in
+// the history table so each one is applied only once.
 type Migrator struct {
-	db *sql.DB
+	db          *sql.DB
+	fsys        fs.FS
+	driftPolicy DriftPolicy
+	lockTimeout time.Duration
 }
 
+// NewMigrator creates a Migrator whose migrationsDir arguments are paths on
+// the local filesystem.
 func NewMigrator(db *sql.DB) *Migrator {
-	return &Migrator{db: db}
+	return &Migrator{db: db, driftPolicy: DriftFail, lockTimeout: DefaultLockTimeout}
 }
 
+// NewMigratorFS creates a Migrator whose migrationsDir arguments are paths
+// within fsys, such as an embed.FS.
+func NewMigratorFS(db *sql.DB, fsys fs.FS) *Migrator {
+	m := NewMigrator(db)
+	m.fsys = fsys
+	return m
+}
+
+// SetDriftPolicy sets how Migrate handles applied migrations whose files
+// changed or disappeared. The default is DriftFail.
+func (m *Migrator) SetDriftPolicy(policy DriftPolicy) {
+	m.driftPolicy = policy
+}
+
+// SetLockTimeout sets how long to wait for another instance holding the
+// migration lock. The default is DefaultLockTimeout.
+func (m *Migrator) SetLockTimeout(timeout time.Duration) {
+	m.lockTimeout = timeout
+}
+
+// Migrate applies the pending migrations in migrationsDir.
 func (m *Migrator) Migrate(migrationsDir string) error {
-	files, err := ioutil.ReadDir(migrationsDir)
+	mm, err := m.load(migrationsDir)
+	if err != nil {
+		return err
+	}
+	return mm.Migrate(context.Background())
+}
+
+// Status reports the state of every migration in migrationsDir.
+func (m *Migrator) Status(migrationsDir string) ([]MigrationStatus, error) {
+	mm, err := m.load(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	return mm.Status(context.Background())
+}
+
+// Rollback reverts the n most recently applied migrations in migrationsDir.
+func (m *Migrator) Rollback(migrationsDir string, n int) error {
+	mm, err := m.load(migrationsDir)
 	if err != nil {
-		return fmt.Errorf("failed to read migrations directory: %w", err)
+		return err
 	}
+	return mm.Rollback(context.Background(), n)
+}
 
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), ".sql") {
-			err := m.applyMigration(filepath.Join(migrationsDir, file.Name()))
-			if err != nil {
-				return fmt.Errorf("failed to apply migration %s: %w", file.Name(), err)
-			}
-		}
+// RollbackTo reverts every applied migration in migrationsDir newer than version.
+func (m *Migrator) RollbackTo(migrationsDir string, version int) error {
+	mm, err := m.load(migrationsDir)
+	if err != nil {
+		return err
 	}
-	return nil
+	return mm.RollbackTo(context.Background(), version)
 }
 
-func (m *Migrator) applyMigration(filePath string) error {
-	migrationSQL, err := ioutil.ReadFile(filePath)
+// Redo rolls back and re-applies the most recently applied migration.
+func (m *Migrator) Redo(migrationsDir string) error {
+	mm, err := m.load(migrationsDir)
 	if err != nil {
-		return fmt.Errorf("failed to read migration file %s: %w", filePath, err)
+		return err
 	}
+	return mm.Redo(context.Background())
+}
 
-	_, err = m.db.Exec(string(migrationSQL))
+// DryRun writes the migrations Migrate would apply from migrationsDir to w,
+// as text or JSON, without changing the schema.
+func (m *Migrator) DryRun(migrationsDir string, w io.Writer, format PlanFormat) error {
+	mm, err := m.load(migrationsDir)
 	if err != nil {
-		return fmt.Errorf("failed to execute migration: %w", err)
+		return err
 	}
+	return mm.DryRun(context.Background(), w, format)
+}
 
-	fmt.Printf("Migration %s applied successfully\n", filepath.Base(filePath))
-	return nil
+// Repair re-baselines the history table against the files in migrationsDir.
+func (m *Migrator) Repair(migrationsDir string) error {
+	mm, err := m.load(migrationsDir)
+	if err != nil {
+		return err
+	}
+	return mm.Repair(context.Background())
 }
 
-func (m *Migrator) Rollback(migrationsDir string) error {
-	// Implement rollback logic if needed
-	return nil
+func (m *Migrator) load(migrationsDir string) (*MigrationManager, error) {
+	mm := NewMigrationManager(m.db)
+	mm.SetDriftPolicy(m.driftPolicy)
+	mm.SetLockTimeout(m.lockTimeout)
+	fsys, dir := m.fsys, migrationsDir
+	if fsys == nil {
+		fsys, dir = os.DirFS(migrationsDir), "."
+	}
+	if err := mm.LoadMigrationsFS(fsys, dir); err != nil {
+		return nil, err
+	}
+	return mm, nil
 }
@@ -0,0 +1,404 @@
+// Package mongo applies versioned migrations to a MongoDB database, using the
+// file naming convention and status types of package migration. It lives in
+// its own package so SQL-only users of migration don't link the Mongo driver.
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yoockh/dbyoc/db/nosql"
+	"github.com/yoockh/dbyoc/logger"
+	"github.com/yoockh/dbyoc/migration"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HistoryCollection records which Mongo migrations have been applied.
+const HistoryCollection = "_migrations"
+
+// MigrationFunc is the body of a Go Mongo migration.
+type MigrationFunc func(ctx context.Context, db *mongo.Database) error
+
+// Migration is a versioned change to a MongoDB database: database
+// commands loaded from a JSON file, or Go functions added with Register.
+type Migration struct {
+	Version     int
+	Description string
+	// Up and Down are database commands run in order with RunCommand.
+	Up   []bson.D
+	Down []bson.D
+	// UpFunc and DownFunc are set instead of Up and Down for Go migrations.
+	UpFunc   MigrationFunc
+	DownFunc MigrationFunc
+	// Checksum is the hex SHA-256 of the up commands in canonical extended
+	// JSON, stored in the history.
+	Checksum string
+}
+
+// historyEntry is a document of the history collection.
+type historyEntry struct {
+	Version       int       `bson:"_id"`
+	Description   string    `bson:"description"`
+	Checksum      string    `bson:"checksum"`
+	AppliedAt     time.Time `bson:"applied_at"`
+	ExecutionTime int64     `bson:"execution_time"`
+	Success       bool      `bson:"success"`
+}
+
+// Migrator applies Migrations in version order and tracks them in
+// the _migrations collection of the client's database.
+//
+// Files follow the SQL naming convention with a .json extension:
+// 0003__add_email_index.json holds either an array of commands (up only) or
+// an object {"up": [...], "down": [...]}, and 0003__add_email_index.up.json /
+// .down.json pairs hold one array each. Commands are MongoDB extended JSON,
+// with the command name as the first key:
+//
+//	[{"createIndexes": "users", "indexes": [{"key": {"email": 1}, "name": "email_1", "unique": true}]}]
+//
+// MongoDB only supports multi-document transactions on replica sets, so a
+// migration that fails halfway is not rolled back; it is recorded as failed
+// and retried by the next Migrate.
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+	logger     *logrus.Logger
+}
+
+// NewMigrator creates a Migrator for the client's database.
+func NewMigrator(client *nosql.MongoDBClient) *Migrator {
+	return &Migrator{
+		db:     client.Database(),
+		logger: logger.GetLogger(),
+	}
+}
+
+// Register adds a Go migration. down may be nil if it cannot be rolled back.
+func (m *Migrator) Register(version int, description string, up, down MigrationFunc) error {
+	if up == nil {
+		return fmt.Errorf("mongo migration %d has no up func", version)
+	}
+	return m.add([]Migration{{
+		Version:     version,
+		Description: description,
+		UpFunc:      up,
+		DownFunc:    down,
+		Checksum:    checksum([]byte("go:" + description)),
+	}})
+}
+
+// LoadMigrations loads .json migrations from the specified directory.
+func (m *Migrator) LoadMigrations(dir string) error {
+	return m.LoadMigrationsFS(os.DirFS(dir), ".")
+}
+
+// LoadMigrationsFS loads .json migrations from dir within fsys, e.g. an embed.FS.
+func (m *Migrator) LoadMigrationsFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migration directory: %w", err)
+	}
+
+	found := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		version, description, direction, err := migration.ParseFileName(entry.Name())
+		if err != nil {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read migration file %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := found[version]
+		if !ok {
+			mig = &Migration{Version: version, Description: description}
+			found[version] = mig
+		}
+		if err := mig.addFile(entry.Name(), direction, data); err != nil {
+			return err
+		}
+	}
+
+	migrations := make([]Migration, 0, len(found))
+	for version, mig := range found {
+		if mig.Up == nil {
+			return fmt.Errorf("mongo migration %d has no up commands", version)
+		}
+		migrations = append(migrations, *mig)
+	}
+	return m.add(migrations)
+}
+
+// addFile sets the up and/or down commands of m from one migration file.
+func (m *Migration) addFile(filename, direction string, data []byte) error {
+	var file struct {
+		Up   []bson.D `bson:"up"`
+		Down []bson.D `bson:"down"`
+	}
+	// A bare array is wrapped so ext JSON can decode it as a document.
+	doc := data
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		key := "up"
+		if direction == "down" {
+			key = "down"
+		}
+		doc = []byte(`{"` + key + `": ` + string(trimmed) + `}`)
+	} else if direction != "" {
+		return fmt.Errorf("mongo migration file %s must contain an array of commands", filename)
+	}
+	if err := bson.UnmarshalExtJSON(doc, false, &file); err != nil {
+		return fmt.Errorf("failed to parse mongo migration file %s: %w", filename, err)
+	}
+
+	if (file.Up != nil && m.Up != nil) || (file.Down != nil && m.Down != nil) {
+		return fmt.Errorf("duplicate migration version %d: %s", m.Version, filename)
+	}
+	if file.Up != nil {
+		// Hash the parsed up commands only, so editing the down section or
+		// the file's formatting is not reported as drift.
+		canonical, err := bson.MarshalExtJSON(bson.D{{Key: "up", Value: file.Up}}, true, false)
+		if err != nil {
+			return fmt.Errorf("failed to checksum mongo migration file %s: %w", filename, err)
+		}
+		m.Up = file.Up
+		m.Checksum = checksum(canonical)
+	}
+	if file.Down != nil {
+		m.Down = file.Down
+	}
+	return nil
+}
+
+func (m *Migrator) add(migrations []Migration) error {
+	for _, mig := range migrations {
+		if _, ok := m.migration(mig.Version); ok {
+			return fmt.Errorf("duplicate migration version %d", mig.Version)
+		}
+	}
+	m.migrations = append(m.migrations, migrations...)
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return nil
+}
+
+// Migrate applies, in version order, every migration not recorded as applied.
+func (m *Migrator) Migrate(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range m.migrations {
+		if a, ok := applied[mig.Version]; ok && a.Success {
+			continue
+		}
+
+		start := time.Now()
+		err := m.run(ctx, mig.UpFunc, mig.Up)
+		duration := time.Since(start)
+		recErr := m.record(ctx, mig, duration, err == nil)
+		if err != nil {
+			if recErr != nil {
+				m.logger.WithError(recErr).Warn("Failed to record failed mongo migration")
+			}
+			return fmt.Errorf("failed to apply mongo migration %d: %w", mig.Version, err)
+		}
+		if recErr != nil {
+			return recErr
+		}
+
+		m.logger.WithFields(logrus.Fields{
+			"version":     mig.Version,
+			"description": mig.Description,
+			"duration_ms": duration.Milliseconds(),
+		}).Info("Mongo migration applied")
+	}
+	return nil
+}
+
+// Rollback reverts the n most recently applied migrations, newest first. n == 0
+// does nothing and a negative n is an error.
+func (m *Migrator) Rollback(ctx context.Context, n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid rollback count %d", n)
+	}
+	if n == 0 {
+		return nil
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	var versions []int
+	for version, a := range applied {
+		if a.Success {
+			versions = append(versions, version)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	if n < len(versions) {
+		versions = versions[:n]
+	}
+
+	migrations := make([]Migration, len(versions))
+	for i, version := range versions {
+		mig, ok := m.migration(version)
+		if !ok {
+			return fmt.Errorf("mongo migration %d is applied but not loaded", version)
+		}
+		if mig.Down == nil && mig.DownFunc == nil {
+			return fmt.Errorf("mongo migration %d has no down commands", version)
+		}
+		migrations[i] = mig
+	}
+
+	history := m.db.Collection(HistoryCollection)
+	for _, mig := range migrations {
+		start := time.Now()
+		if err := m.run(ctx, mig.DownFunc, mig.Down); err != nil {
+			return fmt.Errorf("failed to roll back mongo migration %d: %w", mig.Version, err)
+		}
+		if _, err := history.DeleteOne(ctx, bson.M{"_id": mig.Version}); err != nil {
+			return fmt.Errorf("failed to remove mongo migration %d from %s: %w", mig.Version, HistoryCollection, err)
+		}
+
+		m.logger.WithFields(logrus.Fields{
+			"version":     mig.Version,
+			"description": mig.Description,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}).Info("Mongo migration rolled back")
+	}
+	return nil
+}
+
+// Status reports every loaded or previously applied migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]migration.MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []migration.MigrationStatus
+	for _, mig := range m.migrations {
+		status := migration.MigrationStatus{
+			Version:     mig.Version,
+			Description: mig.Description,
+			State:       migration.StatePending,
+			Checksum:    mig.Checksum,
+		}
+		if a, ok := applied[mig.Version]; ok {
+			status.State = migration.StateFailed
+			if a.Success {
+				status.State = migration.StateApplied
+			}
+			status.Modified = a.Success && a.Checksum != mig.Checksum
+			status.AppliedAt = a.AppliedAt
+			status.ExecutionTime = time.Duration(a.ExecutionTime) * time.Millisecond
+		}
+		statuses = append(statuses, status)
+	}
+	for version, a := range applied {
+		if _, ok := m.migration(version); ok || !a.Success {
+			continue
+		}
+		statuses = append(statuses, migration.MigrationStatus{
+			Version:       a.Version,
+			Description:   a.Description,
+			State:         migration.StateMissing,
+			Checksum:      a.Checksum,
+			AppliedAt:     a.AppliedAt,
+			ExecutionTime: time.Duration(a.ExecutionTime) * time.Millisecond,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// run executes fn, or each command in order.
+func (m *Migrator) run(ctx context.Context, fn MigrationFunc, commands []bson.D) error {
+	if fn != nil {
+		return fn(ctx, m.db)
+	}
+	for i, command := range commands {
+		if err := m.db.RunCommand(ctx, command).Err(); err != nil {
+			name := "command"
+			if len(command) > 0 {
+				name = command[0].Key
+			}
+			return fmt.Errorf("command %d (%s) failed: %w", i+1, name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]historyEntry, error) {
+	cursor, err := m.db.Collection(HistoryCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", HistoryCollection, err)
+	}
+	defer cursor.Close(ctx)
+
+	applied := make(map[int]historyEntry)
+	for cursor.Next(ctx) {
+		var h historyEntry
+		if err := cursor.Decode(&h); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", HistoryCollection, err)
+		}
+		applied[h.Version] = h
+	}
+	return applied, cursor.Err()
+}
+
+// record stores the outcome of running mig, replacing an earlier attempt.
+func (m *Migrator) record(ctx context.Context, mig Migration, duration time.Duration, success bool) error {
+	h := historyEntry{
+		Version:       mig.Version,
+		Description:   mig.Description,
+		Checksum:      mig.Checksum,
+		AppliedAt:     time.Now().UTC(),
+		ExecutionTime: duration.Milliseconds(),
+		Success:       success,
+	}
+	_, err := m.db.Collection(HistoryCollection).ReplaceOne(ctx,
+		bson.M{"_id": mig.Version}, h, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to record mongo migration %d: %w", mig.Version, err)
+	}
+	return nil
+}
+
+func (m *Migrator) migration(version int) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+// checksum returns the hex SHA-256 of data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,41 @@
+package mongo
+
+import "testing"
+
+func TestChecksumCoversUpCommandsOnly(t *testing.T) {
+	load := func(filename, direction, data string) Migration {
+		t.Helper()
+		m := Migration{Version: 1}
+		if err := m.addFile(filename, direction, []byte(data)); err != nil {
+			t.Fatalf("addFile(%s): %v", filename, err)
+		}
+		return m
+	}
+
+	base := load("1__users.json", "", `{"up": [{"create": "users"}], "down": [{"drop": "users"}]}`)
+	if base.Checksum == "" || len(base.Up) != 1 || len(base.Down) != 1 {
+		t.Fatalf("loaded %+v", base)
+	}
+
+	same := []Migration{
+		load("1__users.json", "", `{"up": [{"create": "users"}], "down": [{"drop": "users"}, {"drop": "extra"}]}`),
+		load("1__users.json", "", "{\n  \"up\": [ { \"create\": \"users\" } ]\n}"),
+		load("1__users.json", "", `[{"create": "users"}]`),
+		load("1__users.up.json", "up", `[{"create": "users"}]`),
+	}
+	for i, m := range same {
+		if m.Checksum != base.Checksum {
+			t.Errorf("variant %d: checksum changed without changing the up commands", i)
+		}
+	}
+
+	changed := load("1__users.json", "", `{"up": [{"create": "accounts"}], "down": [{"drop": "users"}]}`)
+	if changed.Checksum == base.Checksum {
+		t.Error("checksum unchanged after editing the up commands")
+	}
+
+	down := load("1__users.down.json", "down", `[{"drop": "users"}]`)
+	if down.Checksum != "" {
+		t.Errorf("down file set checksum %q", down.Checksum)
+	}
+}
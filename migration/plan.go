@@ -0,0 +1,194 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PlanFormat selects how DryRun writes a plan.
+type PlanFormat string
+
+const (
+	PlanText PlanFormat = "text"
+	PlanJSON PlanFormat = "json"
+)
+
+// PlanStep is one pending migration in a MigrationPlan.
+type PlanStep struct {
+	// Order is the 1-based position in which Migrate would run the migration.
+	Order       int    `json:"order"`
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	// Go is set for migrations added with Register, which have no statements.
+	Go bool `json:"go"`
+	// Transactional is false for migrations marked "-- +migrate NoTransaction".
+	Transactional bool `json:"transactional"`
+	// LockReleased is set for a non-transactional step on SQLite, where the
+	// migration lock (a transaction) is committed around it and taken again.
+	LockReleased bool `json:"lock_released,omitempty"`
+	// Retry is set when a previous attempt failed.
+	Retry      bool        `json:"retry"`
+	Statements []Statement `json:"statements,omitempty"`
+}
+
+// MigrationPlan describes what Migrate would do, without doing it.
+type MigrationPlan struct {
+	Dialect Dialect    `json:"dialect"`
+	Steps   []PlanStep `json:"steps"`
+	// Drift is set when applied migrations changed or are missing; with
+	// DriftFail, Migrate would refuse to run.
+	Drift       *DriftError `json:"drift,omitempty"`
+	DriftPolicy DriftPolicy `json:"drift_policy"`
+}
+
+// Plan lists the pending migrations Migrate would apply, in order, with their
+// statements. It only reads the database and does not create the history table.
+func (m *MigrationManager) Plan(ctx context.Context) (*MigrationPlan, error) {
+	applied := map[int]appliedMigration{}
+	exists, err := m.historyExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if applied, err = m.appliedMigrations(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &MigrationPlan{
+		Dialect:     m.dialect,
+		Steps:       []PlanStep{},
+		Drift:       m.detectDrift(applied),
+		DriftPolicy: m.driftPolicy,
+	}
+	for _, migration := range m.migrations {
+		a, ok := applied[migration.Version]
+		if ok && a.Success {
+			continue
+		}
+
+		step := PlanStep{
+			Order:         len(plan.Steps) + 1,
+			Version:       migration.Version,
+			Description:   migration.Description,
+			Go:            migration.UpFunc != nil,
+			Transactional: !migration.NoTransaction,
+			LockReleased:  migration.NoTransaction && m.dialect == DialectSQLite,
+			Retry:         ok,
+		}
+		if !step.Go {
+			statements, err := SplitStatements(migration.Script, m.dialect)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse migration %d: %w", migration.Version, err)
+			}
+			for i := range statements {
+				statements[i].Line += max(migration.scriptLine, 1) - 1
+			}
+			step.Statements = statements
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// DryRun writes the Plan to w as text or JSON without changing the schema.
+func (m *MigrationManager) DryRun(ctx context.Context, w io.Writer, format PlanFormat) error {
+	plan, err := m.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	return plan.Write(w, format)
+}
+
+// Write renders the plan to w as text or JSON.
+func (p *MigrationPlan) Write(w io.Writer, format PlanFormat) error {
+	switch format {
+	case PlanJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	case PlanText, "":
+		_, err := io.WriteString(w, p.String())
+		return err
+	default:
+		return fmt.Errorf("unknown plan format %q", format)
+	}
+}
+
+// String renders the plan as text.
+func (p *MigrationPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dialect: %s\n", p.Dialect)
+	if p.Drift != nil {
+		fmt.Fprintf(&b, "Drift (policy %s): %v\n", p.DriftPolicy, p.Drift)
+	}
+	if len(p.Steps) == 0 {
+		b.WriteString("No pending migrations.\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "Pending migrations: %d\n", len(p.Steps))
+	for _, step := range p.Steps {
+		var notes []string
+		switch {
+		case step.Go:
+			notes = append(notes, "go function")
+		case !step.Transactional:
+			notes = append(notes, "outside transaction")
+		default:
+			notes = append(notes, "in transaction")
+		}
+		if step.LockReleased {
+			notes = append(notes, "migration lock released")
+		}
+		if step.Retry {
+			notes = append(notes, "retry after failure")
+		}
+		fmt.Fprintf(&b, "\n%d. %d %s (%s)\n", step.Order, step.Version, step.Description, strings.Join(notes, ", "))
+		for _, statement := range step.Statements {
+			fmt.Fprintf(&b, "   -- line %d\n", statement.Line)
+			for _, line := range strings.Split(statement.SQL, "\n") {
+				fmt.Fprintf(&b, "   %s\n", line)
+			}
+		}
+	}
+	return b.String()
+}
+
+// historyExists reports whether the history table exists, without creating it.
+func (m *MigrationManager) historyExists(ctx context.Context) (bool, error) {
+	var query string
+	switch m.dialect {
+	case DialectPostgres:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	case DialectMySQL:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	case DialectSQLite:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	default:
+		// Without a catalog query, treat an unreadable table as absent.
+		rows, err := m.conn.QueryContext(ctx, "SELECT version FROM "+HistoryTable+" WHERE 1 = 0")
+		if err != nil {
+			return false, nil
+		}
+		rows.Close()
+		return true, nil
+	}
+
+	rows, err := m.conn.QueryContext(ctx, query, HistoryTable)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for %s table: %w", HistoryTable, err)
+	}
+	defer rows.Close()
+
+	var count int
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return false, fmt.Errorf("failed to check for %s table: %w", HistoryTable, err)
+		}
+	}
+	return count > 0, rows.Err()
+}
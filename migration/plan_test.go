@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPlanMatchesMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	files := fstest.MapFS{
+		"1__create_users.sql": file("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+		"2__vacuum.sql":       file("-- +migrate NoTransaction\nVACUUM;"),
+	}
+	mm := newTestManager(t, db, files)
+
+	plan, err := mm.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if n := count(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE name = '"+HistoryTable+"'"); n != 0 {
+		t.Error("Plan created the history table")
+	}
+	if len(plan.Steps) != 2 {
+		t.Fatalf("plan has %d steps, want 2", len(plan.Steps))
+	}
+	create, vacuum := plan.Steps[0], plan.Steps[1]
+	if !create.Transactional || create.LockReleased {
+		t.Errorf("step 1 = %+v, want transactional without releasing the lock", create)
+	}
+	if vacuum.Transactional || !vacuum.LockReleased || len(vacuum.Statements) != 1 || vacuum.Statements[0].Line != 2 {
+		t.Errorf("step 2 = %+v, want a non-transactional step releasing the lock with VACUUM at line 2", vacuum)
+	}
+
+	text := plan.String()
+	for _, want := range []string{
+		"1. 1 create_users (in transaction)",
+		"2. 2 vacuum (outside transaction, migration lock released)",
+		"   -- line 2\n   VACUUM\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text plan missing %q:\n%s", want, text)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := mm.DryRun(ctx, &buf, PlanJSON); err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	var decoded MigrationPlan
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decoding JSON plan: %v", err)
+	}
+	if len(decoded.Steps) != 2 || !decoded.Steps[1].LockReleased {
+		t.Errorf("JSON plan = %s", buf.String())
+	}
+
+	// Migrate does what the plan said, and nothing is left afterwards.
+	if err := mm.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if plan, err = mm.Plan(ctx); err != nil || len(plan.Steps) != 0 {
+		t.Errorf("plan after Migrate = %+v, %v; want no steps", plan, err)
+	}
+}
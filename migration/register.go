@@ -0,0 +1,68 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MigrationFunc is the body of a Go migration. It runs in the same transaction
+// that records the migration in the history table.
+type MigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[int]Migration)
+)
+
+// Register adds a Go migration, typically from an init function, for data
+// changes that need Go logic (backfills, re-encoding). It is interleaved with
+// SQL migrations by version in every MigrationManager created afterwards and
+// shares their history table, transaction and rollback handling. down may be
+// nil if the migration cannot be rolled back. Register panics if version is
+// already registered or up is nil.
+func Register(version int, description string, up, down MigrationFunc) {
+	if up == nil {
+		panic(fmt.Sprintf("migration: Register of version %d with nil up func", version))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[version]; ok {
+		panic(fmt.Sprintf("migration: Register called twice for version %d", version))
+	}
+	registry[version] = Migration{
+		Version:     version,
+		Description: description,
+		UpFunc:      up,
+		DownFunc:    down,
+		// Go code can't be hashed meaningfully, so drift detection covers the
+		// registered description only.
+		Checksum: checksum([]byte("go:" + description)),
+	}
+}
+
+// registeredMigrations returns the registered Go migrations sorted by version.
+func registeredMigrations() []Migration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	migrations := make([]Migration, 0, len(registry))
+	for _, migration := range registry {
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
+
+func runFunc(ctx context.Context, q execer, fn MigrationFunc) error {
+	tx, ok := q.(*sql.Tx)
+	if !ok {
+		return fmt.Errorf("go migrations must run in a transaction")
+	}
+	return fn(ctx, tx)
+}
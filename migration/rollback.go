@@ -0,0 +1,150 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Rollback reverts the n most recently applied migrations, newest first, by
+// running their down scripts and removing them from the history table. n == 0
+// does nothing and a negative n is an error.
+func (m *MigrationManager) Rollback(ctx context.Context, n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid rollback count %d", n)
+	}
+	if n == 0 {
+		return nil
+	}
+	return m.withLock(ctx, func(locked *MigrationManager) error {
+		return locked.rollback(ctx, n)
+	})
+}
+
+func (m *MigrationManager) rollback(ctx context.Context, n int) error {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+	if n > len(applied) {
+		n = len(applied)
+	}
+	return m.revert(ctx, applied[:n])
+}
+
+// RollbackTo reverts every applied migration newer than version, newest first.
+func (m *MigrationManager) RollbackTo(ctx context.Context, version int) error {
+	return m.withLock(ctx, func(locked *MigrationManager) error {
+		return locked.rollbackTo(ctx, version)
+	})
+}
+
+func (m *MigrationManager) rollbackTo(ctx context.Context, version int) error {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var versions []int
+	for _, v := range applied {
+		if v > version {
+			versions = append(versions, v)
+		}
+	}
+	return m.revert(ctx, versions)
+}
+
+// Redo rolls back the most recently applied migration and applies it again.
+func (m *MigrationManager) Redo(ctx context.Context) error {
+	return m.withLock(ctx, func(locked *MigrationManager) error {
+		return locked.redo(ctx)
+	})
+}
+
+func (m *MigrationManager) redo(ctx context.Context) error {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		return fmt.Errorf("no applied migration to redo")
+	}
+
+	migration, ok := m.migration(applied[0])
+	if !ok {
+		return fmt.Errorf("migration %d is applied but not loaded", applied[0])
+	}
+	if err := m.revert(ctx, applied[:1]); err != nil {
+		return err
+	}
+	return m.apply(ctx, migration)
+}
+
+// appliedVersions returns the successfully applied versions, newest first.
+func (m *MigrationManager) appliedVersions(ctx context.Context) ([]int, error) {
+	if err := m.ensureHistoryTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	for version, a := range applied {
+		if a.Success {
+			versions = append(versions, version)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	return versions, nil
+}
+
+// revert rolls back versions in the given order. Every version is checked for
+// a down script before anything runs.
+func (m *MigrationManager) revert(ctx context.Context, versions []int) error {
+	migrations := make([]Migration, len(versions))
+	for i, version := range versions {
+		migration, ok := m.migration(version)
+		if !ok {
+			return fmt.Errorf("migration %d is applied but not loaded", version)
+		}
+		if !migration.reversible() {
+			return fmt.Errorf("migration %d has no down script", version)
+		}
+		migrations[i] = migration
+	}
+
+	for _, migration := range migrations {
+		start := time.Now()
+		err := m.inTx(ctx, migration.NoTransaction, func(q execer) error {
+			if err := migration.runDown(ctx, q, m.dialect); err != nil {
+				return fmt.Errorf("failed to roll back migration %d: %w", migration.Version, err)
+			}
+			return m.removeMigration(ctx, q, migration.Version)
+		})
+		if err != nil {
+			return err
+		}
+
+		m.logger.WithFields(logrus.Fields{
+			"version":     migration.Version,
+			"description": migration.Description,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}).Info("Migration rolled back")
+	}
+	return nil
+}
+
+// migration returns the loaded migration with the given version.
+func (m *MigrationManager) migration(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
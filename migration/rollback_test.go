@@ -0,0 +1,161 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// reversibleFiles creates tables t1..t3, each dropped by its down section.
+func reversibleFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"1__t1.sql":      file("CREATE TABLE t1 (id INTEGER);\n-- +migrate Down\nDROP TABLE t1;"),
+		"2__t2.up.sql":   file("CREATE TABLE t2 (id INTEGER);"),
+		"2__t2.down.sql": file("DROP TABLE t2;"),
+		"3__t3.sql":      file("-- +migrate Up\nCREATE TABLE t3 (id INTEGER);\n-- +migrate Down\nDROP TABLE t3;"),
+	}
+}
+
+// tables returns which of t1..t3 exist.
+func tables(t *testing.T, mm *MigrationManager) []string {
+	t.Helper()
+	var got []string
+	for _, name := range []string{"t1", "t2", "t3"} {
+		if count(t, mm.db, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = '"+name+"'") == 1 {
+			got = append(got, name)
+		}
+	}
+	return got
+}
+
+func migrated(t *testing.T) *MigrationManager {
+	t.Helper()
+	mm := newTestManager(t, newTestDB(t), reversibleFiles())
+	if err := mm.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return mm
+}
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		n    int
+		want []string
+	}{
+		{"none", 0, []string{"t1", "t2", "t3"}},
+		{"newest", 1, []string{"t1", "t2"}},
+		{"two", 2, []string{"t1"}},
+		{"more than applied", 10, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := migrated(t)
+			if err := mm.Rollback(ctx, tt.n); err != nil {
+				t.Fatalf("Rollback(%d): %v", tt.n, err)
+			}
+			if got := tables(t, mm); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tables after Rollback(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+			applied, err := mm.appliedVersions(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(applied) != len(tt.want) {
+				t.Errorf("applied versions after Rollback(%d) = %v", tt.n, applied)
+			}
+		})
+	}
+
+	mm := migrated(t)
+	if err := mm.Rollback(ctx, -1); err == nil {
+		t.Error("Rollback(-1) succeeded, want error")
+	}
+	if got := tables(t, mm); len(got) != 3 {
+		t.Errorf("Rollback(-1) changed the schema: %v", got)
+	}
+}
+
+func TestRollbackRefusesIrreversible(t *testing.T) {
+	ctx := context.Background()
+	files := reversibleFiles()
+	files["4__t4.sql"] = file("CREATE TABLE t4 (id INTEGER);")
+	mm := newTestManager(t, newTestDB(t), files)
+	if err := mm.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mm.Rollback(ctx, 2); err == nil {
+		t.Fatal("Rollback past a migration without a down script succeeded")
+	}
+	if got := tables(t, mm); len(got) != 3 {
+		t.Errorf("failed Rollback reverted part of the range: %v", got)
+	}
+}
+
+func TestRollbackTo(t *testing.T) {
+	ctx := context.Background()
+	mm := migrated(t)
+
+	if err := mm.RollbackTo(ctx, 1); err != nil {
+		t.Fatalf("RollbackTo(1): %v", err)
+	}
+	if got := tables(t, mm); !reflect.DeepEqual(got, []string{"t1"}) {
+		t.Errorf("tables after RollbackTo(1) = %v, want [t1]", got)
+	}
+
+	if err := mm.RollbackTo(ctx, 0); err != nil {
+		t.Fatalf("RollbackTo(0): %v", err)
+	}
+	if got := tables(t, mm); got != nil {
+		t.Errorf("tables after RollbackTo(0) = %v, want none", got)
+	}
+}
+
+func TestRedo(t *testing.T) {
+	ctx := context.Background()
+	mm := migrated(t)
+	if _, err := mm.db.Exec("INSERT INTO t3 VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mm.Redo(ctx); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+	if got := tables(t, mm); len(got) != 3 {
+		t.Errorf("tables after Redo = %v", got)
+	}
+	// The table was dropped and recreated.
+	if n := count(t, mm.db, "SELECT COUNT(*) FROM t3"); n != 0 {
+		t.Errorf("t3 has %d rows after Redo, want 0", n)
+	}
+
+	empty := newTestManager(t, newTestDB(t), reversibleFiles())
+	if err := empty.Redo(ctx); err == nil {
+		t.Error("Redo with nothing applied succeeded, want error")
+	}
+}
+
+func TestStatementErrorLines(t *testing.T) {
+	ctx := context.Background()
+	files := fstest.MapFS{
+		"1__lines.sql": file("-- +migrate Up\n\nCREATE TABLE t1 (id INTEGER);\n\n-- +migrate Down\n\nDROP TABLE t1;\nDROP TABLE nope;\n"),
+		"2__up.up.sql": file("\nCREATE TABLE t2 (id INTEGER);\nINSERT INTO nope VALUES (1);\n"),
+	}
+	mm := newTestManager(t, newTestDB(t), files)
+
+	var stmtErr *StatementError
+	if err := mm.Migrate(ctx); !errors.As(err, &stmtErr) || stmtErr.Line != 3 {
+		t.Fatalf("Migrate: %v, want a statement error at line 3", err)
+	}
+
+	delete(files, "2__up.up.sql")
+	mm = newTestManager(t, mm.db, files)
+	if err := mm.Rollback(ctx, 1); !errors.As(err, &stmtErr) || stmtErr.Line != 8 {
+		t.Errorf("Rollback: %v, want a statement error at line 8", err)
+	}
+}
@@ -0,0 +1,237 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Statement is one SQL statement of a migration script.
+type Statement struct {
+	SQL string `json:"sql"`
+	// Line is the 1-based line of the script on which the statement starts.
+	Line int `json:"line"`
+}
+
+// StatementError reports which statement of a migration failed.
+type StatementError struct {
+	Line int
+	SQL  string
+	Err  error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement at line %d failed: %v", e.Line, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	dollarTag      = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+	sqliteTrigger  = regexp.MustCompile(`(?i)^CREATE\s+(?:TEMP\s+|TEMPORARY\s+)?TRIGGER\b`)
+	delimiterLine  = regexp.MustCompile(`(?i)^[ \t]*DELIMITER[ \t]+(\S+)[ \t]*(?:\r?\n|$)`)
+	identifierByte = regexp.MustCompile(`[A-Za-z0-9_]`)
+)
+
+// SplitStatements splits script into the statements it contains so they can
+// be executed one at a time. It understands quoted strings and identifiers,
+// -- and /* */ comments (plus # comments and backslash escapes on MySQL),
+// Postgres dollar-quoted bodies, SQLite trigger bodies, and MySQL-client style
+// DELIMITER lines. Comment-only fragments are dropped.
+func SplitStatements(script string, dialect Dialect) ([]Statement, error) {
+	var statements []Statement
+	delimiter := ";"
+	line := 1
+
+	hasCode := false
+	codeStart, codeLine := 0, 0
+	// blocks counts the BEGIN and CASE keywords of the current statement not
+	// yet closed by END, so a trigger body ends at its own END.
+	blocks := 0
+	flush := func(end int) {
+		if hasCode {
+			statements = append(statements, Statement{
+				SQL:  strings.TrimSpace(script[codeStart:end]),
+				Line: codeLine,
+			})
+		}
+		hasCode = false
+		blocks = 0
+	}
+	markCode := func(i int) {
+		if !hasCode {
+			hasCode, codeStart, codeLine = true, i, line
+		}
+	}
+
+	n := len(script)
+	for i := 0; i < n; {
+		c := script[i]
+
+		if (i == 0 || script[i-1] == '\n') && !hasCode {
+			if m := delimiterLine.FindStringSubmatch(script[i:]); m != nil {
+				delimiter = m[1]
+				if strings.HasSuffix(m[0], "\n") {
+					line++
+				}
+				i += len(m[0])
+				continue
+			}
+		}
+
+		switch {
+		case c == '\n':
+			line++
+			i++
+
+		case strings.HasPrefix(script[i:], delimiter) && !continuesTrigger(script, hasCode, codeStart, blocks, delimiter, dialect):
+			flush(i)
+			i += len(delimiter)
+
+		case strings.HasPrefix(script[i:], "--") || (c == '#' && dialect == DialectMySQL):
+			for i < n && script[i] != '\n' {
+				i++
+			}
+
+		case strings.HasPrefix(script[i:], "/*"):
+			startLine := line
+			depth := 0
+			for {
+				if i >= n {
+					return nil, fmt.Errorf("unterminated block comment starting at line %d", startLine)
+				}
+				if strings.HasPrefix(script[i:], "/*") && (depth == 0 || dialect == DialectPostgres) {
+					depth++
+					i += 2
+					continue
+				}
+				if strings.HasPrefix(script[i:], "*/") {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+					continue
+				}
+				if script[i] == '\n' {
+					line++
+				}
+				i++
+			}
+
+		case c == '\'' || c == '"' || (c == '`' && dialect == DialectMySQL):
+			markCode(i)
+			backslash := dialect == DialectMySQL ||
+				(c == '\'' && dialect == DialectPostgres && i > 0 && (script[i-1] == 'E' || script[i-1] == 'e'))
+			end, lines, ok := scanQuoted(script, i, c, backslash)
+			if !ok {
+				return nil, fmt.Errorf("unterminated %c quote starting at line %d", c, line)
+			}
+			line += lines
+			i = end
+
+		case c == '$' && dialect == DialectPostgres && (i == 0 || !identifierByte.MatchString(script[i-1:i])):
+			tag := dollarTag.FindString(script[i:])
+			if tag == "" {
+				markCode(i)
+				i++
+				continue
+			}
+			markCode(i)
+			body := strings.Index(script[i+len(tag):], tag)
+			if body < 0 {
+				return nil, fmt.Errorf("unterminated %s quote starting at line %d", tag, line)
+			}
+			end := i + len(tag) + body + len(tag)
+			line += strings.Count(script[i:end], "\n")
+			i = end
+
+		case isWordStart(c) && (i == 0 || !identifierByte.MatchString(script[i-1:i])):
+			markCode(i)
+			end := i + 1
+			for end < n && identifierByte.MatchString(script[end:end+1]) {
+				end++
+			}
+			switch strings.ToUpper(script[i:end]) {
+			case "BEGIN", "CASE":
+				blocks++
+			case "END":
+				if blocks > 0 {
+					blocks--
+				}
+			}
+			i = end
+
+		default:
+			if c != ' ' && c != '\t' && c != '\r' {
+				markCode(i)
+			}
+			i++
+		}
+	}
+	flush(n)
+	return statements, nil
+}
+
+// runScript executes each statement of script, which starts on firstLine of
+// its file, and returns a *StatementError for the first one that fails.
+func runScript(ctx context.Context, q execer, dialect Dialect, script string, firstLine int) error {
+	statements, err := SplitStatements(script, dialect)
+	if err != nil {
+		return err
+	}
+	if firstLine < 1 {
+		firstLine = 1
+	}
+	for _, statement := range statements {
+		if _, err := q.ExecContext(ctx, statement.SQL); err != nil {
+			return &StatementError{Line: firstLine + statement.Line - 1, SQL: statement.SQL, Err: err}
+		}
+	}
+	return nil
+}
+
+// scanQuoted scans the quoted token starting at script[start] and returns the
+// offset after it and the number of newlines inside it. A doubled quote is an
+// escaped quote, as is a backslash-escaped one when backslash is set.
+func scanQuoted(script string, start int, quote byte, backslash bool) (int, int, bool) {
+	lines := 0
+	for i := start + 1; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if backslash {
+				i++
+				if i < len(script) && script[i] == '\n' {
+					lines++
+				}
+			}
+		case '\n':
+			lines++
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, lines, true
+		}
+	}
+	return 0, 0, false
+}
+
+// continuesTrigger reports whether a ";" inside a SQLite CREATE TRIGGER body
+// belongs to the body rather than ending the statement; only the ";" after
+// the END closing the body's BEGIN does. blocks is the number of BEGIN and
+// CASE keywords still open, so the END of a CASE expression doesn't count.
+func continuesTrigger(script string, hasCode bool, codeStart, blocks int, delimiter string, dialect Dialect) bool {
+	if dialect != DialectSQLite || delimiter != ";" || !hasCode || blocks == 0 {
+		return false
+	}
+	return sqliteTrigger.MatchString(script[codeStart:])
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
@@ -0,0 +1,183 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		script  string
+		want    []Statement
+	}{
+		{
+			name:    "simple",
+			dialect: DialectPostgres,
+			script:  "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want: []Statement{
+				{SQL: "CREATE TABLE a (id INT)", Line: 1},
+				{SQL: "CREATE TABLE b (id INT)", Line: 2},
+			},
+		},
+		{
+			name:    "no trailing semicolon",
+			dialect: DialectSQLite,
+			script:  "SELECT 1;\n\nSELECT 2",
+			want: []Statement{
+				{SQL: "SELECT 1", Line: 1},
+				{SQL: "SELECT 2", Line: 3},
+			},
+		},
+		{
+			name:    "quotes",
+			dialect: DialectPostgres,
+			script:  "INSERT INTO t VALUES ('a;b', 'it''s');\nSELECT \"semi;colon\" FROM t;",
+			want: []Statement{
+				{SQL: "INSERT INTO t VALUES ('a;b', 'it''s')", Line: 1},
+				{SQL: "SELECT \"semi;colon\" FROM t", Line: 2},
+			},
+		},
+		{
+			name:    "multi-line string counts lines",
+			dialect: DialectPostgres,
+			script:  "INSERT INTO t VALUES ('line1\nline2;');\nSELECT 1;",
+			want: []Statement{
+				{SQL: "INSERT INTO t VALUES ('line1\nline2;')", Line: 1},
+				{SQL: "SELECT 1", Line: 3},
+			},
+		},
+		{
+			name:    "postgres escape string",
+			dialect: DialectPostgres,
+			script:  `SELECT E'a\';b'; SELECT 'c\'; SELECT 2;`,
+			want: []Statement{
+				{SQL: `SELECT E'a\';b'`, Line: 1},
+				{SQL: `SELECT 'c\'`, Line: 1},
+				{SQL: "SELECT 2", Line: 1},
+			},
+		},
+		{
+			name:    "mysql backslash and backtick",
+			dialect: DialectMySQL,
+			script:  "INSERT INTO `t;x` VALUES ('a\\';b');\nSELECT 1;",
+			want: []Statement{
+				{SQL: "INSERT INTO `t;x` VALUES ('a\\';b')", Line: 1},
+				{SQL: "SELECT 1", Line: 2},
+			},
+		},
+		{
+			name:    "comments",
+			dialect: DialectPostgres,
+			script:  "-- leading; comment\nSELECT 1; -- trailing; comment\n/* block; /* nested; */ still; */\nSELECT 2;\n-- only a comment\n",
+			want: []Statement{
+				{SQL: "SELECT 1", Line: 2},
+				{SQL: "SELECT 2", Line: 4},
+			},
+		},
+		{
+			name:    "mysql hash comment",
+			dialect: DialectMySQL,
+			script:  "# note; here\nSELECT 1;",
+			want:    []Statement{{SQL: "SELECT 1", Line: 2}},
+		},
+		{
+			name:    "dollar quotes",
+			dialect: DialectPostgres,
+			script: "CREATE FUNCTION f() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql;\n" +
+				"DO $$ BEGIN PERFORM 1; END $$;\nSELECT $1;",
+			want: []Statement{
+				{SQL: "CREATE FUNCTION f() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql", Line: 1},
+				{SQL: "DO $$ BEGIN PERFORM 1; END $$", Line: 6},
+				{SQL: "SELECT $1", Line: 7},
+			},
+		},
+		{
+			name:    "delimiter",
+			dialect: DialectMySQL,
+			script:  "DELIMITER //\nCREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND //\nDELIMITER ;\nCALL p();\n",
+			want: []Statement{
+				{SQL: "CREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND", Line: 2},
+				{SQL: "CALL p()", Line: 8},
+			},
+		},
+		{
+			name:    "sqlite trigger",
+			dialect: DialectSQLite,
+			script:  "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  INSERT INTO log VALUES (1);\n  DELETE FROM b;\nEND;\nSELECT 1;",
+			want: []Statement{
+				{SQL: "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  INSERT INTO log VALUES (1);\n  DELETE FROM b;\nEND", Line: 1},
+				{SQL: "SELECT 1", Line: 5},
+			},
+		},
+		{
+			name:    "sqlite trigger with case",
+			dialect: DialectSQLite,
+			script:  "CREATE TRIGGER t AFTER INSERT ON a BEGIN SELECT CASE WHEN 1 THEN 2 END; DELETE FROM b; END;\nSELECT 1;",
+			want: []Statement{
+				{SQL: "CREATE TRIGGER t AFTER INSERT ON a BEGIN SELECT CASE WHEN 1 THEN 2 END; DELETE FROM b; END", Line: 1},
+				{SQL: "SELECT 1", Line: 2},
+			},
+		},
+		{
+			name:    "sqlite temp trigger with when clause",
+			dialect: DialectSQLite,
+			script:  "CREATE TEMP TRIGGER t BEFORE UPDATE ON a WHEN (CASE new.x WHEN 1 THEN 1 ELSE 0 END) = 1 BEGIN\n  SELECT RAISE(ABORT, 'end;');\nEND;",
+			want: []Statement{
+				{SQL: "CREATE TEMP TRIGGER t BEFORE UPDATE ON a WHEN (CASE new.x WHEN 1 THEN 1 ELSE 0 END) = 1 BEGIN\n  SELECT RAISE(ABORT, 'end;');\nEND", Line: 1},
+			},
+		},
+		{
+			name:    "end as part of an identifier",
+			dialect: DialectSQLite,
+			script:  "CREATE TRIGGER t AFTER INSERT ON a BEGIN UPDATE b SET end_at = 1, backend = 2; END;",
+			want: []Statement{
+				{SQL: "CREATE TRIGGER t AFTER INSERT ON a BEGIN UPDATE b SET end_at = 1, backend = 2; END", Line: 1},
+			},
+		},
+		{
+			name:    "begin outside a trigger",
+			dialect: DialectSQLite,
+			script:  "BEGIN;\nSELECT CASE WHEN 1 THEN 2 END;\nCOMMIT;",
+			want: []Statement{
+				{SQL: "BEGIN", Line: 1},
+				{SQL: "SELECT CASE WHEN 1 THEN 2 END", Line: 2},
+				{SQL: "COMMIT", Line: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitStatements(tt.script, tt.dialect)
+			if err != nil {
+				t.Fatalf("SplitStatements: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitStatements:\n got %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitStatementsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		script  string
+	}{
+		{"unterminated quote", DialectPostgres, "SELECT 'abc;"},
+		{"unterminated identifier", DialectSQLite, "SELECT \"abc;"},
+		{"unterminated block comment", DialectMySQL, "SELECT 1; /* never closed"},
+		{"unterminated dollar quote", DialectPostgres, "DO $x$ BEGIN END $y$;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SplitStatements(tt.script, tt.dialect); err == nil {
+				t.Error("SplitStatements succeeded, want error")
+			}
+		})
+	}
+}